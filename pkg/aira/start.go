@@ -1,6 +1,7 @@
 package aira
 
 import (
+	"context"
 	"log/slog"
 
 	"github.com/flaboy/aira-core/pkg/cluster"
@@ -47,6 +48,16 @@ func Start(cfg *config.InfraConfig) error {
 	return nil
 }
 
+// Stop 在进程退出前调用: 让出主节点以便快速故障转移, 然后停止任务队列
+func Stop(ctx context.Context) error {
+	err := cluster.Stop(ctx)
+	if err != nil {
+		slog.Error("cluster stop failed", "error", err)
+	}
+	tasklib.StopAsynq()
+	return err
+}
+
 // 兼容性函数 - 保持向后兼容
 func Init(cfg *config.InfraConfig) error {
 	return Start(cfg)
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/redis"
+)
+
+// invalidation 是通过 redis pub/sub 广播的 L1 失效消息
+type invalidation struct {
+	Keys   []string `json:"keys,omitempty"`
+	All    bool     `json:"all,omitempty"`
+	Origin string   `json:"origin,omitempty"` // 发送方 Cache 的id, 发送方已在本地处理过
+}
+
+const subscribeRetryInterval = 5 * time.Second
+
+// ensureListener 惰性订阅失效频道; 失败后每隔一段时间重试, 期间 L1 仍按 TTL 过期
+func (c *Cache) ensureListener() {
+	if c.local == nil {
+		return
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if c.closed.Load() || c.sub != nil || time.Since(c.subTried) < subscribeRetryInterval {
+		return
+	}
+	c.subTried = time.Now()
+
+	sub, err := redis.SubscribeJSON[invalidation](context.Background(), c.channel)
+	if err != nil {
+		slog.Warn("cache subscribe invalidation channel failed", "channel", c.channel, "error", err)
+		return
+	}
+	c.sub = sub
+	go c.listen(sub)
+}
+
+func (c *Cache) listen(sub *redis.JSONSubscription[invalidation]) {
+	for {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				c.subMu.Lock()
+				c.sub = nil
+				c.subMu.Unlock()
+				c.local.purge()
+				return
+			}
+			if msg.Err != nil {
+				slog.Warn("cache invalid invalidation message", "channel", c.channel, "error", msg.Err)
+				continue
+			}
+			// 自己发出的消息在发送前已处理, 晚到时不能移除之后写入 L1 的新值
+			if msg.Data.Origin == c.id {
+				continue
+			}
+			if msg.Data.All {
+				c.local.purge()
+			} else {
+				c.local.remove(msg.Data.Keys...)
+			}
+		case state := <-sub.States():
+			// 断线期间可能错过失效消息, 重连后清空 L1
+			if state.Connected {
+				c.local.purge()
+			}
+		}
+	}
+}
+
+// broadcast 通知所有节点 (包括本节点) 移除 L1 中的 keys
+func (c *Cache) broadcast(ctx context.Context, msg invalidation) {
+	if c.local == nil {
+		return
+	}
+	if msg.All {
+		c.local.purge()
+	} else {
+		c.local.remove(msg.Keys...)
+	}
+	msg.Origin = c.id
+	if err := redis.PublishJSON(ctx, c.channel, msg); err != nil {
+		slog.Warn("cache publish invalidation failed", "channel", c.channel, "error", err)
+	}
+}
+
+// FlushLocal 清空所有节点的 L1 缓存, redis 中的数据不受影响
+func (c *Cache) FlushLocal(ctx context.Context) {
+	c.broadcast(ctx, invalidation{All: true})
+}
+
+// Close 停止接收失效广播. 之后无法得知其他节点的写入, L1 被清空且不再使用, 读写只经过 redis.
+func (c *Cache) Close() error {
+	c.subMu.Lock()
+	c.closed.Store(true)
+	sub := c.sub
+	c.sub = nil
+	c.subMu.Unlock()
+	if c.local != nil {
+		c.local.purge()
+	}
+	if sub != nil {
+		return sub.Close()
+	}
+	return nil
+}
+
+type stats struct {
+	requests    atomic.Uint64
+	localHits   atomic.Uint64
+	redisHits   atomic.Uint64
+	redisMisses atomic.Uint64
+	redisErrors atomic.Uint64
+	loads       atomic.Uint64
+}
+
+// Stats 是缓存的命中统计
+type Stats struct {
+	Requests     uint64
+	LocalHits    uint64
+	RedisHits    uint64
+	RedisMisses  uint64
+	RedisErrors  uint64
+	Loads        uint64
+	LocalEntries int
+}
+
+// HitRatio 返回 L1 和 redis 的总命中率
+func (s Stats) HitRatio() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.LocalHits+s.RedisHits) / float64(s.Requests)
+}
+
+// LocalHitRatio 返回 L1 命中率
+func (s Stats) LocalHitRatio() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.LocalHits) / float64(s.Requests)
+}
+
+// Stats 返回自启动以来的命中统计
+func (c *Cache) Stats() Stats {
+	s := Stats{
+		Requests:    c.stats.requests.Load(),
+		LocalHits:   c.stats.localHits.Load(),
+		RedisHits:   c.stats.redisHits.Load(),
+		RedisMisses: c.stats.redisMisses.Load(),
+		RedisErrors: c.stats.redisErrors.Load(),
+		Loads:       c.stats.loads.Load(),
+	}
+	if c.local != nil {
+		s.LocalEntries = c.local.len()
+	}
+	return s
+}
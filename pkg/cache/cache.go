@@ -0,0 +1,459 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/redis"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound 表示缓存未命中; loader 返回它时表示数据不存在, 可被负缓存
+var ErrNotFound = errors.New("cache: not found")
+
+type Options struct {
+	Prefix      string        // key 前缀, 默认 "cache:"
+	Codec       Codec         // 序列化方式, 默认 JSON
+	StaleTTL    time.Duration // 过期后仍返回旧值并在后台刷新的时长, 0 表示不启用
+	NegativeTTL time.Duration // loader 返回 ErrNotFound 时缓存"不存在"的时长, 0 表示不缓存
+	Local       *LocalOptions // 非 nil 时在 redis 前增加进程内 L1 缓存, 命中时返回共享的值, 见 LocalOptions
+}
+
+// Cache 是基于 redis 的应用缓存, 可选带进程内 L1.
+// L1 的写入和失效通过 redis pub/sub 广播到所有节点; redis 暂时不可用时只使用 L1.
+type Cache struct {
+	opts  Options
+	group singleflight.Group
+	stats stats
+
+	id       string // 区分本实例发出的失效广播
+	local    *localCache
+	channel  string
+	subMu    sync.Mutex
+	sub      *redis.JSONSubscription[invalidation]
+	subTried time.Time
+	closed   atomic.Bool // Close 之后不再使用 L1, 也不再订阅
+}
+
+func New(opts Options) *Cache {
+	if opts.Prefix == "" {
+		opts.Prefix = "cache:"
+	}
+	if opts.Codec == nil {
+		opts.Codec = JSON
+	}
+	c := &Cache{opts: opts, id: uuid.NewString()}
+	if opts.Local != nil {
+		local := *opts.Local
+		c.local = newLocalCache(&local)
+		c.channel = local.Channel
+		if c.channel == "" {
+			c.channel = opts.Prefix + "invalidate"
+		}
+	}
+	return c
+}
+
+var defaultCache = New(Options{})
+
+// Default 返回包级函数使用的默认缓存
+func Default() *Cache {
+	return defaultCache
+}
+
+// Loader 在缓存未命中时加载数据
+type Loader[T any] func(ctx context.Context) (T, error)
+
+type Option func(*itemOptions)
+
+type itemOptions struct {
+	tags        []string
+	staleTTL    time.Duration
+	negativeTTL time.Duration
+}
+
+// WithTags 给缓存项打标签, 之后可通过 InvalidateTags 批量失效
+func WithTags(tags ...string) Option {
+	return func(o *itemOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// WithStaleTTL 覆盖 Options.StaleTTL
+func WithStaleTTL(d time.Duration) Option {
+	return func(o *itemOptions) {
+		o.staleTTL = d
+	}
+}
+
+// WithNegativeTTL 覆盖 Options.NegativeTTL
+func WithNegativeTTL(d time.Duration) Option {
+	return func(o *itemOptions) {
+		o.negativeTTL = d
+	}
+}
+
+func (c *Cache) itemOptions(opts []Option) *itemOptions {
+	o := &itemOptions{
+		staleTTL:    c.opts.StaleTTL,
+		negativeTTL: c.opts.NegativeTTL,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+const (
+	flagValue    byte = 1
+	flagNotFound byte = 2
+)
+
+// entry 在 redis 中的格式: 1 字节标记 + 8 字节新鲜截止时间(毫秒) + 编码后的值
+type entry struct {
+	flag       byte
+	freshUntil int64
+	payload    []byte
+}
+
+func (e *entry) fresh() bool {
+	return time.Now().UnixMilli() < e.freshUntil
+}
+
+func (e *entry) encode() []byte {
+	buf := make([]byte, 9+len(e.payload))
+	buf[0] = e.flag
+	binary.BigEndian.PutUint64(buf[1:9], uint64(e.freshUntil))
+	copy(buf[9:], e.payload)
+	return buf
+}
+
+func decodeEntry(data []byte) (*entry, bool) {
+	if len(data) < 9 || (data[0] != flagValue && data[0] != flagNotFound) {
+		return nil, false
+	}
+	return &entry{
+		flag:       data[0],
+		freshUntil: int64(binary.BigEndian.Uint64(data[1:9])),
+		payload:    data[9:],
+	}, true
+}
+
+func (c *Cache) key(key string) string {
+	return redis.Key(c.opts.Prefix + key)
+}
+
+func (c *Cache) tagKey(tag string) string {
+	return redis.Key(c.opts.Prefix + "tag:" + tag)
+}
+
+// read 返回缓存项, 未命中时返回 nil, nil
+func (c *Cache) read(ctx context.Context, key string) (*entry, error) {
+	data, err := redis.RedisClient.Get(ctx, c.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		c.stats.redisMisses.Add(1)
+		return nil, nil
+	}
+	if err != nil {
+		c.stats.redisErrors.Add(1)
+		return nil, err
+	}
+	e, ok := decodeEntry(data)
+	if !ok {
+		c.stats.redisMisses.Add(1)
+		return nil, nil
+	}
+	c.stats.redisHits.Add(1)
+	return e, nil
+}
+
+// lookupLocal 查询 L1, 未启用 L1 时总是未命中
+func (c *Cache) lookupLocal(key string) (*localItem, bool) {
+	c.stats.requests.Add(1)
+	if c.local == nil || c.closed.Load() {
+		return nil, false
+	}
+	c.ensureListener()
+	item, ok := c.local.get(key)
+	if ok {
+		c.stats.localHits.Add(1)
+	}
+	return item, ok
+}
+
+func (c *Cache) storeLocal(key string, value interface{}, notFound bool, freshUntil int64) {
+	if c.local != nil && !c.closed.Load() && time.Now().UnixMilli() < freshUntil {
+		c.local.set(key, value, notFound, time.UnixMilli(freshUntil))
+	}
+}
+
+// tagScript 将 key 加入标签集合, 并保证集合的过期时间不短于其中任何一项.
+// 集合已不过期 (其中有不过期的项) 时保持不过期, 只有新建或已有过期时间的集合才延长.
+var tagScript = redis.RegisterScript("cache_tag", `
+local existed = redis.call('exists', KEYS[1])
+redis.call('sadd', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl == 0 then
+	redis.call('persist', KEYS[1])
+else
+	local pttl = redis.call('pttl', KEYS[1])
+	if existed == 0 or (pttl >= 0 and pttl < ttl) then
+		redis.call('pexpire', KEYS[1], ttl)
+	end
+end
+return 1
+`)
+
+func (c *Cache) write(ctx context.Context, key string, e *entry, ttl time.Duration, o *itemOptions) error {
+	if ttl > 0 {
+		e.freshUntil = time.Now().Add(ttl).UnixMilli()
+		ttl += o.staleTTL
+	} else {
+		e.freshUntil = math.MaxInt64
+	}
+
+	if err := redis.RedisClient.Set(ctx, c.key(key), e.encode(), ttl).Err(); err != nil {
+		return err
+	}
+	for _, tag := range o.tags {
+		err := tagScript.Run(ctx, []string{c.tagKey(tag)}, c.key(key), ttl.Milliseconds()).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete 删除缓存项, 并通知所有节点移除 L1 中的副本
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	full := make([]string, len(keys))
+	for i, key := range keys {
+		full[i] = c.key(key)
+	}
+	err := del(ctx, full)
+	c.broadcast(ctx, invalidation{Keys: keys})
+	return err
+}
+
+// InvalidateTags 删除带有任一标签的全部缓存项
+func (c *Cache) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		tagKey := c.tagKey(tag)
+		keys, err := redis.RedisClient.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+		if err := del(ctx, append(keys, tagKey)); err != nil {
+			return err
+		}
+		for i, key := range keys {
+			keys[i] = strings.TrimPrefix(redis.StripKey(key), c.opts.Prefix)
+		}
+		c.broadcast(ctx, invalidation{Keys: keys})
+	}
+	return nil
+}
+
+// del 逐个删除, 避免 cluster 模式下多 key 命令跨 slot
+func del(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := redis.RedisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
+
+// Typed 是绑定了值类型的缓存视图
+type Typed[T any] struct {
+	c *Cache
+}
+
+// For 返回缓存 c 上类型为 T 的视图
+func For[T any](c *Cache) Typed[T] {
+	return Typed[T]{c: c}
+}
+
+// Get 读取缓存, 未命中或命中负缓存时返回 ErrNotFound
+func (t Typed[T]) Get(ctx context.Context, key string) (T, error) {
+	if v, err, ok := t.getLocal(key); ok {
+		return v, err
+	}
+
+	var zero T
+	e, err := t.c.read(ctx, key)
+	if err != nil {
+		return zero, err
+	}
+	if e == nil {
+		return zero, ErrNotFound
+	}
+	v, err := t.decode(e)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.c.storeLocal(key, v, e.flag == flagNotFound, e.freshUntil)
+	}
+	return v, err
+}
+
+// Set 写入缓存, ttl 为 0 表示不过期; 其他节点 L1 中的旧值会被移除
+func (t Typed[T]) Set(ctx context.Context, key string, v T, ttl time.Duration, opts ...Option) error {
+	e, err := t.set(ctx, key, v, ttl, t.c.itemOptions(opts))
+	t.c.broadcast(ctx, invalidation{Keys: []string{key}})
+	if e != nil {
+		t.c.storeLocal(key, v, false, e.freshUntil)
+	}
+	return err
+}
+
+// set 写入 redis, 编码成功时即使 redis 写入失败也返回 entry, 以便写入 L1
+func (t Typed[T]) set(ctx context.Context, key string, v T, ttl time.Duration, o *itemOptions) (*entry, error) {
+	payload, err := t.c.opts.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{flag: flagValue, payload: payload}
+	return e, t.c.write(ctx, key, e, ttl, o)
+}
+
+func (t Typed[T]) decode(e *entry) (T, error) {
+	var v T
+	if e.flag == flagNotFound {
+		return v, ErrNotFound
+	}
+	err := t.c.opts.Codec.Unmarshal(e.payload, &v)
+	return v, err
+}
+
+func (t Typed[T]) getLocal(key string) (T, error, bool) {
+	var zero T
+	item, ok := t.c.lookupLocal(key)
+	if !ok {
+		return zero, nil, false
+	}
+	if item.notFound {
+		return zero, ErrNotFound, true
+	}
+	v, ok := item.value.(T)
+	return v, nil, ok
+}
+
+// GetOrLoad 读取缓存, 未命中时调用 loader 并写入缓存.
+// 同一进程内同一 key 的并发加载只会调用一次 loader; 过期但仍在 StaleTTL 内的值会被直接返回,
+// 同时在后台刷新. redis 不可用时直接调用 loader.
+func (t Typed[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader Loader[T], opts ...Option) (T, error) {
+	if v, err, ok := t.getLocal(key); ok {
+		return v, err
+	}
+
+	o := t.c.itemOptions(opts)
+	e, err := t.c.read(ctx, key)
+	if err != nil {
+		// redis 不可用, 加载结果只写入 L1
+		slog.Warn("cache read failed, loading without redis", "key", key, "error", err)
+		return t.load(ctx, key, ttl, loader, o)
+	}
+
+	if e != nil {
+		v, err := t.decode(e)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			if e.fresh() {
+				t.c.storeLocal(key, v, e.flag == flagNotFound, e.freshUntil)
+			} else {
+				t.refresh(ctx, key, ttl, loader, o)
+			}
+			return v, err
+		}
+		// 值无法解码 (如结构体变更), 当作未命中处理
+		slog.Warn("cache decode failed, reloading", "key", key, "error", err)
+	}
+	return t.load(ctx, key, ttl, loader, o)
+}
+
+func (t Typed[T]) load(ctx context.Context, key string, ttl time.Duration, loader Loader[T], o *itemOptions) (T, error) {
+	v, err, _ := t.c.group.Do(t.flightKey(key), func() (interface{}, error) {
+		return t.loadAndStore(ctx, key, ttl, loader, o)
+	})
+	result, _ := v.(T)
+	return result, err
+}
+
+// flightKey 区分不同类型的视图, 同一个 key 以不同类型加载时不会合并
+func (t Typed[T]) flightKey(key string) string {
+	return fmt.Sprintf("%T:%s", (*T)(nil), key)
+}
+
+// refresh 在后台重新加载过期值, 不阻塞当前请求
+func (t Typed[T]) refresh(ctx context.Context, key string, ttl time.Duration, loader Loader[T], o *itemOptions) {
+	ctx = context.WithoutCancel(ctx)
+	t.c.group.DoChan(t.flightKey(key), func() (interface{}, error) {
+		return t.loadAndStore(ctx, key, ttl, loader, o)
+	})
+}
+
+func (t Typed[T]) loadAndStore(ctx context.Context, key string, ttl time.Duration, loader Loader[T], o *itemOptions) (T, error) {
+	t.c.stats.loads.Add(1)
+	v, err := loader(ctx)
+	if errors.Is(err, ErrNotFound) {
+		if o.negativeTTL > 0 {
+			noStale := *o
+			noStale.staleTTL = 0
+			e := &entry{flag: flagNotFound}
+			if err := t.c.write(ctx, key, e, o.negativeTTL, &noStale); err != nil {
+				slog.Warn("cache write failed", "key", key, "error", err)
+			}
+			t.c.storeLocal(key, nil, true, e.freshUntil)
+		}
+		return v, ErrNotFound
+	}
+	if err != nil {
+		return v, err
+	}
+	e, err := t.set(ctx, key, v, ttl, o)
+	if err != nil {
+		slog.Warn("cache write failed", "key", key, "error", err)
+	}
+	if e != nil {
+		t.c.storeLocal(key, v, false, e.freshUntil)
+	}
+	return v, nil
+}
+
+// GetOrLoad 在默认缓存上执行 Typed.GetOrLoad
+func GetOrLoad[T any](ctx context.Context, key string, ttl time.Duration, loader Loader[T], opts ...Option) (T, error) {
+	return For[T](defaultCache).GetOrLoad(ctx, key, ttl, loader, opts...)
+}
+
+// Get 从默认缓存读取
+func Get[T any](ctx context.Context, key string) (T, error) {
+	return For[T](defaultCache).Get(ctx, key)
+}
+
+// Set 写入默认缓存
+func Set[T any](ctx context.Context, key string, v T, ttl time.Duration, opts ...Option) error {
+	return For[T](defaultCache).Set(ctx, key, v, ttl, opts...)
+}
+
+// Delete 从默认缓存删除
+func Delete(ctx context.Context, keys ...string) error {
+	return defaultCache.Delete(ctx, keys...)
+}
+
+// InvalidateTags 在默认缓存上按标签失效
+func InvalidateTags(ctx context.Context, tags ...string) error {
+	return defaultCache.InvalidateTags(ctx, tags...)
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LocalOptions 配置进程内的 L1 缓存.
+// L1 保存解码后的值, 每次命中返回同一个值; T 为指针、map 或 slice 时调用方不能修改返回值,
+// 需要修改时应先复制.
+type LocalOptions struct {
+	MaxEntries int           // 最多保存的条目数, 默认 10000
+	TTL        time.Duration // 条目在 L1 中的最长保存时间, 默认 1 分钟
+	Channel    string        // 失效广播频道, 默认 Prefix + "invalidate"
+}
+
+// localCache 是带 TTL 的 LRU, 保存已解码的值
+type localCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type localItem struct {
+	key       string
+	value     interface{}
+	notFound  bool
+	expiresAt time.Time
+}
+
+func newLocalCache(opts *LocalOptions) *localCache {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 10000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	return &localCache{
+		maxEntries: opts.MaxEntries,
+		ttl:        opts.TTL,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (l *localCache) get(key string) (*localItem, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*localItem)
+	if time.Now().After(item.expiresAt) {
+		l.removeElement(el)
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return item, true
+}
+
+// set 保存值, 过期时间不超过 freshUntil
+func (l *localCache) set(key string, value interface{}, notFound bool, freshUntil time.Time) {
+	expiresAt := time.Now().Add(l.ttl)
+	if freshUntil.Before(expiresAt) {
+		expiresAt = freshUntil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	item := &localItem{key: key, value: value, notFound: notFound, expiresAt: expiresAt}
+	if el, ok := l.items[key]; ok {
+		el.Value = item
+		l.ll.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.ll.PushFront(item)
+	for l.ll.Len() > l.maxEntries {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+func (l *localCache) remove(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := l.items[key]; ok {
+			l.removeElement(el)
+		}
+	}
+}
+
+func (l *localCache) purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.items = make(map[string]*list.Element)
+}
+
+func (l *localCache) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
+
+func (l *localCache) removeElement(el *list.Element) {
+	l.ll.Remove(el)
+	delete(l.items, el.Value.(*localItem).key)
+}
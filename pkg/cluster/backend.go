@@ -0,0 +1,187 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/config"
+)
+
+// ElectionBackend 是选主使用的锁服务
+type ElectionBackend interface {
+	// Acquire 尝试成为 key 的持有者, 已由 node 持有时续约; 返回 node 是否持有
+	Acquire(ctx context.Context, key, node string, lease time.Duration) (bool, error)
+	// Renew 续约 node 持有的 key, 已不再持有时返回 false
+	Renew(ctx context.Context, key, node string, lease time.Duration) (bool, error)
+	// Release 释放 node 持有的 key, 并通知等待的节点
+	Release(ctx context.Context, key, node string) error
+	// Leader 返回 key 当前的持有者和租约到期时间, 无法得知时返回空字符串
+	Leader(ctx context.Context, key string) (string, time.Time, error)
+	// Watch 返回的 channel 在 key 被释放时收到通知, ctx 结束时关闭; 不支持时返回 nil
+	Watch(ctx context.Context, key string) <-chan struct{}
+}
+
+var (
+	backend   ElectionBackend
+	backendMu sync.Mutex
+)
+
+// SetElectionBackend 替换选主后端, 应在 cluster.Start 之前调用
+func SetElectionBackend(b ElectionBackend) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	backend = b
+}
+
+// electionBackend 返回当前后端, 未设置时按 CLUSTER_BACKEND 配置创建.
+// 配置无效时返回的后端在每次调用时返回该错误, 由 cluster.Start 报告给调用方.
+func electionBackend() ElectionBackend {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	if backend == nil {
+		b, err := NewElectionBackend(backendName())
+		if err != nil {
+			return errorBackend{err}
+		}
+		backend = b
+	}
+	return backend
+}
+
+// backendName 返回 CLUSTER_BACKEND 配置, 未加载配置时为空, 即使用 redis
+func backendName() string {
+	if config.Config == nil {
+		return ""
+	}
+	return config.Config.Cluster.Backend
+}
+
+// NewElectionBackend 按名称创建选主后端: redis (默认), database 或 memory.
+// database 后端无法得知其他节点持有的锁, Leader 在从节点上返回空字符串.
+func NewElectionBackend(name string) (ElectionBackend, error) {
+	switch strings.ToLower(name) {
+	case "", "redis":
+		return &redisBackend{}, nil
+	case "database", "db":
+		return newDatabaseBackend(), nil
+	case "memory":
+		return NewMemoryBackend(), nil
+	}
+	return nil, fmt.Errorf("cluster: unknown election backend: %s", name)
+}
+
+// errorBackend 是无法创建的后端, 所有操作返回创建时的错误
+type errorBackend struct {
+	err error
+}
+
+func (b errorBackend) Acquire(ctx context.Context, key, node string, lease time.Duration) (bool, error) {
+	return false, b.err
+}
+
+func (b errorBackend) Renew(ctx context.Context, key, node string, lease time.Duration) (bool, error) {
+	return false, b.err
+}
+
+func (b errorBackend) Release(ctx context.Context, key, node string) error {
+	return b.err
+}
+
+func (b errorBackend) Leader(ctx context.Context, key string) (string, time.Time, error) {
+	return "", time.Time{}, b.err
+}
+
+func (b errorBackend) Watch(ctx context.Context, key string) <-chan struct{} {
+	return nil
+}
+
+// memoryBackend 在进程内选主, 用于单进程开发和测试
+type memoryBackend struct {
+	mu       sync.Mutex
+	leases   map[string]memoryLease
+	watchers map[string][]chan struct{}
+}
+
+type memoryLease struct {
+	node     string
+	expireAt time.Time
+}
+
+func NewMemoryBackend() ElectionBackend {
+	return &memoryBackend{
+		leases:   make(map[string]memoryLease),
+		watchers: make(map[string][]chan struct{}),
+	}
+}
+
+func (b *memoryBackend) Acquire(ctx context.Context, key, node string, lease time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cur, ok := b.leases[key]
+	if ok && cur.node != node && time.Now().Before(cur.expireAt) {
+		return false, nil
+	}
+	b.leases[key] = memoryLease{node: node, expireAt: time.Now().Add(lease)}
+	return true, nil
+}
+
+func (b *memoryBackend) Renew(ctx context.Context, key, node string, lease time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cur, ok := b.leases[key]
+	if !ok || cur.node != node || !time.Now().Before(cur.expireAt) {
+		return false, nil
+	}
+	b.leases[key] = memoryLease{node: node, expireAt: time.Now().Add(lease)}
+	return true, nil
+}
+
+func (b *memoryBackend) Release(ctx context.Context, key, node string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cur, ok := b.leases[key]; ok && cur.node == node {
+		delete(b.leases, key)
+		for _, ch := range b.watchers[key] {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+func (b *memoryBackend) Leader(ctx context.Context, key string) (string, time.Time, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cur, ok := b.leases[key]
+	if !ok || !time.Now().Before(cur.expireAt) {
+		return "", time.Time{}, nil
+	}
+	return cur.node, cur.expireAt, nil
+}
+
+func (b *memoryBackend) Watch(ctx context.Context, key string) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.watchers[key] = append(b.watchers[key], ch)
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		list := b.watchers[key]
+		for i, c := range list {
+			if c == ch {
+				b.watchers[key] = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/database"
+)
+
+// databaseBackend 使用数据库会话锁选主. 锁随持有连接存在, 不需要租约;
+// 进程崩溃或网络断开时数据库在会话结束后释放锁. 只能得知本节点是否持有.
+type databaseBackend struct {
+	mu    sync.Mutex
+	locks map[string]*dbLock
+}
+
+type dbLock struct {
+	node string
+	lock *database.SessionLock
+}
+
+func newDatabaseBackend() *databaseBackend {
+	return &databaseBackend{locks: make(map[string]*dbLock)}
+}
+
+func (b *databaseBackend) Acquire(ctx context.Context, key, node string, lease time.Duration) (bool, error) {
+	b.mu.Lock()
+	_, held := b.locks[key]
+	b.mu.Unlock()
+	if held {
+		return b.Renew(ctx, key, node, lease)
+	}
+
+	lock, ok, err := database.TryLock(ctx, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	b.mu.Lock()
+	b.locks[key] = &dbLock{node: node, lock: lock}
+	b.mu.Unlock()
+	return true, nil
+}
+
+func (b *databaseBackend) Renew(ctx context.Context, key, node string, lease time.Duration) (bool, error) {
+	b.mu.Lock()
+	l, ok := b.locks[key]
+	b.mu.Unlock()
+	if !ok || l.node != node {
+		return false, nil
+	}
+	if err := l.lock.Ping(ctx); err != nil {
+		// 连接已断开, 锁已被数据库释放
+		b.drop(ctx, key, l)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (b *databaseBackend) Release(ctx context.Context, key, node string) error {
+	b.mu.Lock()
+	l, ok := b.locks[key]
+	b.mu.Unlock()
+	if !ok || l.node != node {
+		return nil
+	}
+	return b.drop(ctx, key, l)
+}
+
+func (b *databaseBackend) drop(ctx context.Context, key string, l *dbLock) error {
+	b.mu.Lock()
+	if b.locks[key] == l {
+		delete(b.locks, key)
+	}
+	b.mu.Unlock()
+	return l.lock.Unlock(ctx)
+}
+
+// Leader 只能得知本节点是否持有锁: 本节点持有时返回本节点id, 否则返回空字符串,
+// 即使其他节点持有. 数据库会话锁不记录持有者, 租约到期时间也总是零值.
+func (b *databaseBackend) Leader(ctx context.Context, key string) (string, time.Time, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if l, ok := b.locks[key]; ok {
+		return l.node, time.Time{}, nil
+	}
+	return "", time.Time{}, nil
+}
+
+func (b *databaseBackend) Watch(ctx context.Context, key string) <-chan struct{} {
+	return nil
+}
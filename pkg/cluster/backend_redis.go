@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/redis"
+)
+
+// KEYS[1] 选主 key; ARGV[1] 节点id, ARGV[2] 租约毫秒数.
+// 未被占用时抢占, 已由本节点持有时续约, 返回 1 表示本节点持有
+var acquireScript = redis.RegisterScript("cluster_acquire", `
+if redis.call('set', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	redis.call('pexpire', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// KEYS[1] 选主 key; ARGV[1] 节点id, ARGV[2] 租约毫秒数. 只续约本节点持有的 key
+var renewScript = redis.RegisterScript("cluster_renew", `
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('pexpire', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// KEYS[1] 选主 key; ARGV[1] 节点id. 只删除本节点持有的 key
+var releaseScript = redis.RegisterScript("cluster_release", `
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('del', KEYS[1])
+end
+return 0
+`)
+
+// resignChannel 通知从节点主节点已主动让出, 消息内容为选主 key
+const resignChannel = "cluster:resigned"
+
+// redisBackend 使用带租约的 redis key 选主
+type redisBackend struct{}
+
+func (redisBackend) Acquire(ctx context.Context, key, node string, lease time.Duration) (bool, error) {
+	n, err := acquireScript.Run(ctx, []string{redis.Key(key)}, node, lease.Milliseconds()).Int64()
+	return n == 1, err
+}
+
+func (redisBackend) Renew(ctx context.Context, key, node string, lease time.Duration) (bool, error) {
+	n, err := renewScript.Run(ctx, []string{redis.Key(key)}, node, lease.Milliseconds()).Int64()
+	return n == 1, err
+}
+
+func (redisBackend) Release(ctx context.Context, key, node string) error {
+	n, err := releaseScript.Run(ctx, []string{redis.Key(key)}, node).Int64()
+	if err != nil || n == 0 {
+		return err
+	}
+	if err := redis.PublishContext(ctx, resignChannel, key); err != nil {
+		slog.Warn("Cluster election publish resign failed", "key", key, "error", err)
+	}
+	return nil
+}
+
+func (redisBackend) Leader(ctx context.Context, key string) (string, time.Time, error) {
+	node, err := redis.RedisClient.Get(ctx, redis.Key(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	ttl, err := redis.RedisClient.PTTL(ctx, redis.Key(key)).Result()
+	if err != nil || ttl < 0 {
+		return node, time.Time{}, err
+	}
+	return node, time.Now().Add(ttl), nil
+}
+
+func (redisBackend) Watch(ctx context.Context, key string) <-chan struct{} {
+	sub, err := redis.SubscribeContext(ctx, resignChannel)
+	if err != nil {
+		// 订阅失败时退化为轮询
+		slog.Warn("Cluster election subscribe failed", "key", key, "error", err)
+		return nil
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		defer sub.Close()
+		for msg := range sub.Messages() {
+			if msg.Payload != key {
+				continue
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch
+}
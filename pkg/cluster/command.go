@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/redis"
+	"github.com/google/uuid"
+)
+
+const (
+	// 命令频道, 消息为 commandRequest; 回复发到请求方独占的 replyTo 频道
+	commandChannel = "cluster:commands"
+
+	defaultCommandTimeout = 5 * time.Second
+)
+
+// CommandHandler 处理广播命令, 返回值会序列化为 JSON 回复给发送方
+type CommandHandler func(ctx context.Context, args json.RawMessage) (interface{}, error)
+
+var (
+	commands   = make(map[string]CommandHandler)
+	commandsMu sync.RWMutex
+)
+
+func init() {
+	HandleCommand("ping", func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+		return Self(), nil
+	})
+}
+
+// HandleCommand 注册本节点的命令处理器, 例如:
+//
+//	cluster.HandleCommand("cache:flush-local", func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+//		cache.Default().FlushLocal(ctx)
+//		return nil, nil
+//	})
+func HandleCommand(name string, h CommandHandler) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	commands[name] = h
+}
+
+type commandRequest struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Args     json.RawMessage `json:"args,omitempty"`
+	Targets  []string        `json:"targets,omitempty"`
+	ReplyTo  string          `json:"reply_to"`
+	Deadline time.Time       `json:"deadline"`
+}
+
+// CommandReply 是一个节点的回复
+type CommandReply struct {
+	Node   string          `json:"node"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// BroadcastOptions 是 Broadcast 的选项
+type BroadcastOptions struct {
+	Targets []string      // 目标节点id, 为空时发送给所有存活节点
+	Timeout time.Duration // 等待回复的最长时间, 默认 5 秒, 不超过 ctx 的截止时间
+}
+
+// BroadcastResult 是广播的结果
+type BroadcastResult struct {
+	Replies map[string]CommandReply // 按节点id
+	Missing []string                // 超时未回复的节点
+}
+
+// Broadcast 向集群节点发送命令并收集回复, 超时后返回已收到的回复和未回复的节点:
+//
+//	res, err := cluster.Broadcast(ctx, "cache:flush-local", nil, nil)
+func Broadcast(ctx context.Context, name string, args interface{}, opts *BroadcastOptions) (*BroadcastResult, error) {
+	o := BroadcastOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+
+	var raw json.RawMessage
+	if args != nil {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	}
+
+	members, err := Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[string]bool)
+	for _, m := range members {
+		if len(o.Targets) == 0 || slices.Contains(o.Targets, m.ID) {
+			expected[m.ID] = true
+		}
+	}
+	// 指定的目标不在成员列表中时也等待, 超时后报告为未回复
+	for _, id := range o.Targets {
+		expected[id] = true
+	}
+
+	req := commandRequest{
+		ID:      uuid.NewString(),
+		Name:    name,
+		Args:    raw,
+		Targets: o.Targets,
+	}
+	req.ReplyTo = "cluster:replies:" + req.ID
+	req.Deadline, _ = ctx.Deadline()
+
+	// 先订阅回复频道再发送命令, 避免丢失回复
+	sub, err := redis.SubscribeJSON[CommandReply](ctx, req.ReplyTo)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Close()
+	if err := redis.PublishJSON(ctx, commandChannel, req); err != nil {
+		return nil, err
+	}
+
+	// 只统计 expected 中节点的回复, 快照之后加入的节点回复不计入, 以免提前结束等待
+	result := &BroadcastResult{Replies: make(map[string]CommandReply)}
+wait:
+	for len(result.Replies) < len(expected) {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				break wait
+			}
+			if msg.Err == nil && expected[msg.Data.Node] {
+				result.Replies[msg.Data.Node] = msg.Data
+			}
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	for id := range expected {
+		if _, ok := result.Replies[id]; !ok {
+			result.Missing = append(result.Missing, id)
+		}
+	}
+	sort.Strings(result.Missing)
+	return result, nil
+}
+
+// listenCommands 接收并执行发给本节点的命令
+func listenCommands(ctx context.Context) {
+	for ctx.Err() == nil {
+		sub, err := redis.SubscribeJSON[commandRequest](ctx, commandChannel)
+		if err != nil {
+			slog.Warn("Cluster subscribe commands failed", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(memberHeartbeat):
+			}
+			continue
+		}
+		for msg := range sub.Messages() {
+			if msg.Err != nil {
+				slog.Warn("Cluster invalid command", "error", msg.Err)
+				continue
+			}
+			req := msg.Data
+			if len(req.Targets) > 0 && !slices.Contains(req.Targets, nodeID) {
+				continue
+			}
+			go execCommand(ctx, &req)
+		}
+		sub.Close()
+	}
+}
+
+func execCommand(ctx context.Context, req *commandRequest) {
+	if !req.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, req.Deadline)
+		defer cancel()
+	}
+
+	reply := CommandReply{Node: nodeID}
+	result, err := runCommand(ctx, req)
+	if err == nil && result != nil {
+		reply.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	if err := redis.PublishJSON(ctx, req.ReplyTo, reply); err != nil {
+		slog.Warn("Cluster command reply failed", "command", req.Name, "error", err)
+	}
+}
+
+func runCommand(ctx context.Context, req *commandRequest) (result interface{}, err error) {
+	commandsMu.RLock()
+	h, ok := commands[req.Name]
+	commandsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown command: %s", req.Name)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Cluster command panic", "command", req.Name, "error", r)
+			err = fmt.Errorf("command panic: %v", r)
+		}
+	}()
+	return h(ctx, req.Args)
+}
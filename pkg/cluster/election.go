@@ -0,0 +1,312 @@
+package cluster
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// ElectionOptions 是 NewElection 的选项
+type ElectionOptions struct {
+	LockTime time.Duration   // 从节点的抢占周期, 默认 30 秒; 租约为其 4/3, 主节点每 1/3 续约一次
+	Backend  ElectionBackend // 为 nil 时使用 CLUSTER_BACKEND 配置的后端
+}
+
+type Election struct {
+	name      string
+	runUid    string // 进程唯一id
+	key       string
+	lockTime  time.Duration
+	backend   ElectionBackend
+	initFuncs []func()
+	initDone  bool // initFuncs 每个进程只执行一次
+
+	mu          sync.Mutex
+	isRunNode   bool
+	lastRenewed time.Time
+	leaderCtx   context.Context
+	cancel      context.CancelFunc
+	onElected   []func(ctx context.Context)
+	onDemoted   []func()
+	holdoff     time.Time     // 主动让出后在此之前不参与抢占
+	transition  time.Time     // 最近一次当选或降级的时间
+	wake        chan struct{} // 收到让出通知时立即尝试抢占
+}
+
+var (
+	elections   = make(map[string]*Election)
+	electionsMu sync.Mutex
+	started     context.Context // Start 之后非 nil, 之后创建的选举立即开始运行
+)
+
+// NewElection 创建一个独立的具名选举, 不同的单例工作可以由不同的节点担任主节点:
+//
+//	relay := cluster.NewElection("outbox-relay", nil)
+//	relay.OnElected(func(ctx context.Context) { runRelay(ctx) })
+//
+// 同名选举只能创建一次. 在 cluster.Start 之后创建的选举会立即开始运行.
+func NewElection(name string, opts *ElectionOptions) *Election {
+	return newElection(name, "cluster_election:"+name, opts)
+}
+
+func newElection(name, key string, opts *ElectionOptions) *Election {
+	o := ElectionOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.LockTime <= 0 {
+		o.LockTime = 30 * time.Second
+	}
+
+	electionsMu.Lock()
+	defer electionsMu.Unlock()
+	if _, exists := elections[name]; exists {
+		panic("cluster: election already exists: " + name)
+	}
+	k := &Election{
+		name:      name,
+		runUid:    nodeID,
+		key:       key,
+		lockTime:  o.LockTime,
+		backend:   o.Backend,
+		initFuncs: []func(){},
+		wake:      make(chan struct{}, 1),
+	}
+	elections[name] = k
+	if started != nil {
+		go k.RunContext(started)
+	}
+	return k
+}
+
+// Elections 返回所有具名选举, 包括 Master()
+func Elections() []*Election {
+	electionsMu.Lock()
+	defer electionsMu.Unlock()
+	list := make([]*Election, 0, len(elections))
+	for _, k := range elections {
+		list = append(list, k)
+	}
+	return list
+}
+
+// GetElection 按名称返回选举, 不存在时返回 nil
+func GetElection(name string) *Election {
+	electionsMu.Lock()
+	defer electionsMu.Unlock()
+	return elections[name]
+}
+
+func (k *Election) Name() string {
+	return k.name
+}
+
+// lease 是 key 的过期时间, 比续约周期多留余量
+func (k *Election) lease() time.Duration {
+	return k.lockTime * 4 / 3
+}
+
+func (k *Election) getBackend() ElectionBackend {
+	if k.backend != nil {
+		return k.backend
+	}
+	return electionBackend()
+}
+
+// renewInterval 是主节点的续约周期
+func (k *Election) renewInterval() time.Duration {
+	return k.lockTime / 3
+}
+
+// Run 持续参与选主: 从节点每 lockTime 尝试抢占, 主节点定期续约,
+// 续约发现 key 已被其他节点持有, 或超过 lockTime 未能续约时降级.
+func (k *Election) Run() {
+	k.RunContext(context.Background())
+}
+
+// RunContext 与 Run 相同, ctx 结束时降级并返回
+func (k *Election) RunContext(ctx context.Context) {
+	// 后端不支持通知时只按 lockTime 轮询
+	if released := k.getBackend().Watch(ctx, k.key); released != nil {
+		go k.listenResign(released)
+	}
+
+	for {
+		interval := k.step(ctx)
+		select {
+		case <-ctx.Done():
+			k.demote("stopped")
+			return
+		case <-k.wake:
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (k *Election) listenResign(released <-chan struct{}) {
+	for range released {
+		if k.IsMaster() {
+			continue
+		}
+		select {
+		case k.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// step 执行一次抢占或续约, 返回到下一次执行的间隔
+func (k *Election) step(ctx context.Context) time.Duration {
+	if !k.IsMaster() {
+		k.mu.Lock()
+		holdoff := time.Until(k.holdoff)
+		k.mu.Unlock()
+		if holdoff > 0 {
+			return holdoff
+		}
+		if Paused() {
+			return k.lockTime
+		}
+
+		ok, err := k.getBackend().Acquire(ctx, k.key, k.runUid, k.lease())
+		if err != nil {
+			slog.Warn("Cluster election acquire failed", "key", k.key, "error", err)
+			return k.lockTime
+		}
+		if !ok {
+			return k.lockTime
+		}
+		if !k.promote() {
+			// 抢占期间本节点被暂停或让出, 立即释放
+			if err := k.getBackend().Release(ctx, k.key, k.runUid); err != nil {
+				slog.Warn("Cluster election release failed", "key", k.key, "error", err)
+			}
+			return k.lockTime
+		}
+		return k.renewInterval()
+	}
+
+	ok, err := k.getBackend().Renew(ctx, k.key, k.runUid, k.lease())
+	switch {
+	case err == nil && ok:
+		k.mu.Lock()
+		k.lastRenewed = time.Now()
+		k.mu.Unlock()
+	case err == nil:
+		k.demote("lease taken over")
+	default:
+		slog.Warn("Cluster election renew failed", "key", k.key, "error", err)
+		// 无法确认仍持有 key, 在 key 可能过期之前主动降级
+		k.mu.Lock()
+		expired := time.Since(k.lastRenewed) >= k.lockTime
+		k.mu.Unlock()
+		if expired {
+			k.demote("renew timeout")
+		}
+	}
+	return k.renewInterval()
+}
+
+// promote 在抢占成功后当选. 与 Resign 在同一把锁下检查暂停和让出状态,
+// 抢占期间被暂停或让出时不当选并返回 false.
+func (k *Election) promote() bool {
+	k.mu.Lock()
+	if Paused() || time.Now().Before(k.holdoff) {
+		k.mu.Unlock()
+		return false
+	}
+	k.isRunNode = true
+	k.lastRenewed = time.Now()
+	k.transition = k.lastRenewed
+	k.leaderCtx, k.cancel = context.WithCancel(context.Background())
+	ctx := k.leaderCtx
+	var initFuncs []func()
+	if !k.initDone {
+		initFuncs, k.initDone = k.initFuncs, true
+	}
+	callbacks := k.onElected
+	k.mu.Unlock()
+
+	machine, _ := os.Hostname()
+	slog.Info("Cluster master elected", "election", k.name, "machine", machine, "runUid", k.runUid)
+
+	// 执行初始化函数, 与旧版本一样只在首次当选时执行
+	for _, f := range initFuncs {
+		f()
+	}
+	for _, f := range callbacks {
+		go f(ctx)
+	}
+	return true
+}
+
+func (k *Election) demote(reason string) {
+	k.mu.Lock()
+	if !k.isRunNode {
+		k.mu.Unlock()
+		return
+	}
+	k.isRunNode = false
+	k.transition = time.Now()
+	k.cancel()
+	k.leaderCtx, k.cancel = nil, nil
+	callbacks := k.onDemoted
+	k.mu.Unlock()
+
+	slog.Warn("Cluster master demoted", "election", k.name, "runUid", k.runUid, "reason", reason)
+	for _, f := range callbacks {
+		f()
+	}
+}
+
+// Resign 主动让出主节点: 先降级停止主节点工作, 再释放本节点持有的 key,
+// 并通知从节点立即抢占. 之后 lockTime 内本节点不参与抢占. 进程退出前应调用.
+func (k *Election) Resign(ctx context.Context) error {
+	k.mu.Lock()
+	k.holdoff = time.Now().Add(k.lockTime)
+	k.mu.Unlock()
+
+	if !k.IsMaster() {
+		return nil
+	}
+	k.demote("resigned")
+	return k.getBackend().Release(ctx, k.key, k.runUid)
+}
+
+// IsMaster 返回本节点当前是否为主节点
+func (k *Election) IsMaster() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.isRunNode
+}
+
+// AddInitFunc 添加首次当选时同步执行的初始化函数, 每个进程只执行一次, 之后添加的不会执行.
+// 初始化函数启动的工作在降级后不会停止.
+//
+// Deprecated: 使用 OnElected, 当选工作应在 ctx 结束 (降级) 时停止, 再次当选时重新启动.
+func (k *Election) AddInitFunc(f func()) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.initFuncs = append(k.initFuncs, f)
+}
+
+// OnElected 注册当选回调, 每次当选时在新的 goroutine 中执行.
+// ctx 在失去主节点身份时取消, 只应由主节点执行的工作应在 ctx 结束后停止.
+// 注册时已是主节点则立即执行.
+func (k *Election) OnElected(f func(ctx context.Context)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onElected = append(k.onElected, f)
+	if k.isRunNode {
+		go f(k.leaderCtx)
+	}
+}
+
+// OnDemoted 注册降级回调, 在失去主节点身份后同步执行
+func (k *Election) OnDemoted(f func()) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onDemoted = append(k.onDemoted, f)
+}
@@ -2,83 +2,116 @@ package cluster
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
-	"time"
 
+	"github.com/flaboy/aira-core/pkg/database"
 	"github.com/flaboy/aira-core/pkg/redis"
+	"github.com/google/uuid"
 )
 
-var master *Election
+var (
+	master         *Election
+	runCancel      context.CancelFunc
+	membershipDone chan struct{}
+)
 
 const clusterKey = "cluster_master"
 
+// nodeID 是进程唯一id: 主机名_pid_随机串, 同一主机上重启的进程也不会重复
+var nodeID string
+
 func init() {
-	// machine, _ := os.Hostname()
-	// pid := os.Getpid()
-	master = &Election{
-		// runUid:    machine + "_" + string(pid), // 进程唯一id
-		lockTime:  30,
-		initFuncs: []func(){},
-	}
-}
+	machine, _ := os.Hostname()
+	nodeID = fmt.Sprintf("%s_%d_%s", machine, os.Getpid(), uuid.NewString()[:8])
 
-// 使用redis的能力，实现选主
-func Start() error {
-	go master.Run()
-	return nil
+	master = newElection(clusterKey, clusterKey, nil)
 }
 
-func Master() *Election {
-	return master
+// NodeID 返回本进程在集群中的唯一id
+func NodeID() string {
+	return nodeID
 }
 
-type Election struct {
-	runUid    string // 进程唯一id
-	isRunNode bool
-	lockTime  int
-	initFuncs []func()
-}
+// 启动所有已创建的选举, 并开始上报成员心跳. 选主后端由 CLUSTER_BACKEND 配置,
+// 配置无效或 database 后端未调用 database.Start 时返回错误
+func Start() error {
+	backendMu.Lock()
+	if backend == nil {
+		b, err := NewElectionBackend(backendName())
+		if err != nil {
+			backendMu.Unlock()
+			return err
+		}
+		backend = b
+	}
+	_, useDatabase := backend.(*databaseBackend)
+	backendMu.Unlock()
+	if useDatabase && database.Database() == nil {
+		return fmt.Errorf("cluster: database election backend: %w", database.ErrNotStarted)
+	}
 
-func (k *Election) Run() {
-	// 标识当前节点抢到执行权利
-	for !k.check() {
-		time.Sleep(time.Duration(k.lockTime) * time.Second)
+	electionsMu.Lock()
+	defer electionsMu.Unlock()
+	if started != nil {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	started, runCancel = ctx, cancel
+	for _, k := range elections {
+		go k.RunContext(ctx)
 	}
 
-	// 执行初始化函数
-	for _, f := range k.initFuncs {
-		f()
+	// 成员注册和广播命令依赖 redis, 未使用 redis 时只提供选主
+	if redis.RedisClient == nil {
+		slog.Info("Cluster membership disabled, redis is not initialized")
+		return nil
 	}
-}
+	go listenCommands(ctx)
 
-func (k *Election) AddInitFunc(f func()) {
-	k.initFuncs = append(k.initFuncs, f)
+	membershipDone = make(chan struct{})
+	go func() {
+		defer close(membershipDone)
+		runMembership(ctx)
+	}()
+	return nil
 }
 
-func (k *Election) check() bool {
-	if k.isRunNode {
-		return true
+// Stop 让出所有主节点, 停止参与选主并退出集群, 以便其他节点立即接管
+func Stop(ctx context.Context) error {
+	var errs []error
+	for _, k := range Elections() {
+		if err := k.Resign(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("resign %s: %w", k.name, err))
+		}
 	}
 
-	// 沉默节点, 尝试检查runNode是否死机, 抢夺执行权利
-	ctx := context.Background()
-	ok := redis.RedisClient.SetNX(ctx, clusterKey, k.runUid, time.Duration(k.lockTime+10)*time.Second)
-
-	if ok.Val() {
-		k.isRunNode = true
-		// 设置一个保持心跳的循环
-		go func() {
-			ex := time.Duration(k.lockTime) * time.Second
-			for range time.Tick(ex) {
-				redis.RedisClient.Expire(context.Background(), clusterKey, time.Duration(k.lockTime+10)*time.Second)
-			}
-		}()
-		machine, _ := os.Hostname()
-		slog.Info("Cluster master elected", "machine", machine, "runUid", k.runUid)
-		return true
-	} else {
-		k.isRunNode = false
-		return false
+	electionsMu.Lock()
+	if runCancel != nil {
+		runCancel()
 	}
+	done := membershipDone
+	started, runCancel, membershipDone = nil, nil, nil
+	electionsMu.Unlock()
+
+	if done != nil {
+		<-done
+		if err := leave(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("leave cluster: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// membershipRunning 返回本节点是否正在上报成员心跳
+func membershipRunning() bool {
+	electionsMu.Lock()
+	defer electionsMu.Unlock()
+	return membershipDone != nil
+}
+
+func Master() *Election {
+	return master
 }
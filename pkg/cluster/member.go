@@ -0,0 +1,247 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/redis"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	memberHeartbeat = 5 * time.Second
+	memberTTL       = 3 * memberHeartbeat
+
+	// 成员变更频道, 消息为 MemberEvent
+	membersChannel = "cluster:members"
+)
+
+// ErrNoRedis 表示 redis 未初始化, 成员注册和广播命令不可用
+var ErrNoRedis = errors.New("cluster: membership requires redis")
+
+const (
+	MemberJoined = "join"
+	MemberLeft   = "leave"
+)
+
+// Member 是集群中的一个节点
+type Member struct {
+	ID        string            `json:"id"`
+	Hostname  string            `json:"hostname"`
+	PID       int               `json:"pid"`
+	Version   string            `json:"version,omitempty"`
+	StartedAt time.Time         `json:"started_at"`
+	LastSeen  time.Time         `json:"last_seen"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Paused    bool              `json:"paused,omitempty"`
+}
+
+// MemberEvent 是节点加入或离开的通知
+type MemberEvent struct {
+	Type   string `json:"type"`
+	Member Member `json:"member"`
+}
+
+var (
+	self     Member
+	selfMu   sync.Mutex
+	selfJoin bool
+)
+
+func init() {
+	hostname, _ := os.Hostname()
+	self = Member{
+		Hostname:  hostname,
+		PID:       os.Getpid(),
+		StartedAt: time.Now(),
+	}
+}
+
+func memberKey(id string) string {
+	return redis.Key("cluster", "member", id)
+}
+
+func membersKey() string {
+	return redis.Key("cluster", "members")
+}
+
+// SetVersion 设置本节点上报的版本号
+func SetVersion(version string) {
+	selfMu.Lock()
+	defer selfMu.Unlock()
+	self.Version = version
+}
+
+// SetMetadata 设置本节点上报的自定义元数据, 下一次心跳时生效
+func SetMetadata(key, value string) {
+	selfMu.Lock()
+	defer selfMu.Unlock()
+	if self.Metadata == nil {
+		self.Metadata = make(map[string]string)
+	}
+	self.Metadata[key] = value
+}
+
+// Self 返回本节点的成员信息
+func Self() Member {
+	selfMu.Lock()
+	defer selfMu.Unlock()
+	m := self
+	m.ID = nodeID
+	m.Paused = Paused()
+	m.Metadata = maps.Clone(self.Metadata)
+	return m
+}
+
+// runMembership 定期上报心跳并清理失联节点, ctx 结束时退出集群
+func runMembership(ctx context.Context) {
+	ticker := time.NewTicker(memberHeartbeat)
+	defer ticker.Stop()
+	for {
+		if err := heartbeat(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Cluster member heartbeat failed", "error", err)
+		}
+		if err := pruneMembers(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Cluster prune members failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func heartbeat(ctx context.Context) error {
+	m := Self()
+	m.LastSeen = time.Now()
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return err
+	}
+
+	var added *goredis.IntCmd
+	_, err = redis.RedisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Set(ctx, memberKey(m.ID), data, memberTTL)
+		added = pipe.ZAdd(ctx, membersKey(), goredis.Z{Score: float64(m.LastSeen.UnixMilli()), Member: m.ID})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 首次上报, 或被其他节点判定失联并清理后重新加入
+	selfMu.Lock()
+	joined := !selfJoin || added.Val() == 1
+	selfJoin = true
+	selfMu.Unlock()
+	if joined {
+		publishMemberEvent(ctx, MemberJoined, m)
+	}
+	return nil
+}
+
+// pruneMembers 清理心跳超时的节点. 多个节点可能同时清理, 只有成功移除的节点发出离开通知.
+func pruneMembers(ctx context.Context) error {
+	deadline := time.Now().Add(-memberTTL).UnixMilli()
+	ids, err := redis.RedisClient.ZRangeByScore(ctx, membersKey(), &goredis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(deadline, 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		n, err := redis.RedisClient.ZRem(ctx, membersKey(), id).Result()
+		if err != nil {
+			return err
+		}
+		if n == 1 {
+			slog.Info("Cluster member expired", "member", id)
+			publishMemberEvent(ctx, MemberLeft, Member{ID: id})
+		}
+	}
+	return nil
+}
+
+// leave 主动退出集群
+func leave(ctx context.Context) error {
+	m := Self()
+	_, err := redis.RedisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Del(ctx, memberKey(m.ID))
+		pipe.ZRem(ctx, membersKey(), m.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	selfMu.Lock()
+	selfJoin = false
+	selfMu.Unlock()
+	publishMemberEvent(ctx, MemberLeft, m)
+	return nil
+}
+
+func publishMemberEvent(ctx context.Context, typ string, m Member) {
+	if err := redis.PublishJSON(ctx, membersChannel, MemberEvent{Type: typ, Member: m}); err != nil {
+		slog.Warn("Cluster publish member event failed", "type", typ, "member", m.ID, "error", err)
+	}
+}
+
+// Members 返回当前存活的节点, 按 id 排序
+func Members(ctx context.Context) ([]Member, error) {
+	if redis.RedisClient == nil {
+		return nil, ErrNoRedis
+	}
+	deadline := time.Now().Add(-memberTTL).UnixMilli()
+	ids, err := redis.RedisClient.ZRangeByScore(ctx, membersKey(), &goredis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(deadline, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// 逐个读取, cluster 模式下成员 key 可能不在同一个 slot
+	cmds := make([]*goredis.StringCmd, len(ids))
+	_, err = redis.RedisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.Get(ctx, memberKey(id))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	members := make([]Member, 0, len(ids))
+	for _, cmd := range cmds {
+		data, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		var m Member
+		if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+	return members, nil
+}
+
+// WatchMembers 订阅节点加入和离开的通知. 离开通知中只保证 Member.ID 有值.
+func WatchMembers(ctx context.Context) (*redis.JSONSubscription[MemberEvent], error) {
+	return redis.SubscribeJSON[MemberEvent](ctx, membersChannel)
+}
@@ -0,0 +1,241 @@
+package cluster
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/redis"
+)
+
+// PartitionOptions 是 NewPartitioner 的选项
+type PartitionOptions struct {
+	Shards int // 逻辑分片数量
+
+	// HandoffGrace 是分片分配给本节点后到开始处理前的等待时间, 默认 10 秒.
+	// 原持有节点在此期间收到变更并释放分片, 避免两个节点同时处理同一分片.
+	HandoffGrace time.Duration
+
+	// OnAcquire 在本节点开始负责分片时在新的 goroutine 中调用, ctx 在分片被释放时取消
+	OnAcquire func(ctx context.Context, shard int)
+	// OnRelease 在分片被释放后同步调用
+	OnRelease func(shard int)
+}
+
+// Partitioner 按 rendezvous 哈希将逻辑分片分配给存活节点, 节点加入或离开时只有少量分片迁移
+type Partitioner struct {
+	name string
+	opts PartitionOptions
+
+	mu      sync.Mutex
+	owned   map[int]context.CancelFunc
+	pending map[int]time.Time // 等待交接的分片及可以开始处理的时间
+	synced  time.Time         // 最近一次成功读取成员列表的时间
+}
+
+// NewPartitioner 创建分片分配器, 所有节点应使用相同的 name 和分片数量:
+//
+//	p := cluster.NewPartitioner("feed-poller", cluster.PartitionOptions{
+//		Shards:    64,
+//		OnAcquire: func(ctx context.Context, shard int) { pollFeeds(ctx, shard) },
+//	})
+//	go p.Run(ctx)
+func NewPartitioner(name string, opts PartitionOptions) *Partitioner {
+	if opts.Shards <= 0 {
+		panic("cluster: partitioner requires at least one shard")
+	}
+	if opts.HandoffGrace <= 0 {
+		opts.HandoffGrace = 10 * time.Second
+	}
+	return &Partitioner{
+		name:    name,
+		opts:    opts,
+		owned:   make(map[int]context.CancelFunc),
+		pending: make(map[int]time.Time),
+	}
+}
+
+// Owner 返回分片在给定节点中的归属节点
+func (p *Partitioner) Owner(shard int, members []string) string {
+	var owner string
+	var best uint64
+	for _, id := range members {
+		if w := p.weight(shard, id); owner == "" || w > best {
+			owner, best = id, w
+		}
+	}
+	return owner
+}
+
+func (p *Partitioner) weight(shard int, member string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(p.name))
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(shard))
+	h.Write(buf[:])
+	h.Write([]byte(member))
+	return mix64(h.Sum64())
+}
+
+// mix64 是 murmur3 的 fmix64. FNV 对结尾字节的扩散很弱, 相近的节点id (如 node-1, node-2)
+// 权重相关, 不混合时分片会明显偏向部分节点.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Owned 返回本节点当前负责的分片
+func (p *Partitioner) Owned() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	shards := make([]int, 0, len(p.owned))
+	for shard := range p.owned {
+		shards = append(shards, shard)
+	}
+	slices.Sort(shards)
+	return shards
+}
+
+// Run 跟踪集群成员并调整本节点负责的分片, ctx 结束时释放所有分片并返回.
+// 需要先调用 cluster.Start 以便本节点出现在成员列表中.
+func (p *Partitioner) Run(ctx context.Context) {
+	var changes <-chan redis.JSONMessage[MemberEvent]
+	events, err := WatchMembers(ctx)
+	if err != nil {
+		// 订阅失败时只按心跳周期轮询成员
+		slog.Warn("Cluster partitioner watch members failed", "partitioner", p.name, "error", err)
+	} else {
+		defer events.Close()
+		changes = events.Messages()
+	}
+
+	poll := time.NewTicker(memberHeartbeat)
+	defer poll.Stop()
+	check := time.NewTicker(time.Second)
+	defer check.Stop()
+
+	p.rebalance(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			p.releaseAll()
+			return
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			p.rebalance(ctx)
+		case <-poll.C:
+			p.rebalance(ctx)
+		case <-check.C:
+			p.acquireDue(ctx)
+		}
+	}
+}
+
+// rebalance 重新计算分配: 不再属于本节点的分片立即释放, 新分配的分片等待 HandoffGrace 后开始处理
+func (p *Partitioner) rebalance(ctx context.Context) {
+	members, err := Members(ctx)
+	if err != nil {
+		slog.Warn("Cluster partitioner list members failed", "partitioner", p.name, "error", err)
+		// 短暂失败时保持现状; 超过 memberTTL 时其他节点可能已将本节点清理并接管分片, 主动释放
+		p.mu.Lock()
+		expired := !p.synced.IsZero() && time.Since(p.synced) >= memberTTL &&
+			len(p.owned)+len(p.pending) > 0
+		p.mu.Unlock()
+		if expired {
+			slog.Warn("Cluster partitioner lost membership, releasing shards", "partitioner", p.name)
+			p.releaseAll()
+		}
+		return
+	}
+	// 已暂停的节点不参与分配
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		if !m.Paused {
+			ids = append(ids, m.ID)
+		}
+	}
+
+	p.mu.Lock()
+	p.synced = time.Now()
+	var released []int
+	for shard := 0; shard < p.opts.Shards; shard++ {
+		mine := p.Owner(shard, ids) == nodeID
+		_, owned := p.owned[shard]
+		_, pending := p.pending[shard]
+		switch {
+		case mine && !owned && !pending:
+			p.pending[shard] = time.Now().Add(p.opts.HandoffGrace)
+		case !mine && pending:
+			delete(p.pending, shard)
+		case !mine && owned:
+			p.owned[shard]()
+			delete(p.owned, shard)
+			released = append(released, shard)
+		}
+	}
+	p.mu.Unlock()
+
+	p.notifyReleased(released)
+	p.acquireDue(ctx)
+}
+
+func (p *Partitioner) acquireDue(ctx context.Context) {
+	now := time.Now()
+	p.mu.Lock()
+	var acquired []int
+	for shard, at := range p.pending {
+		if now.Before(at) {
+			continue
+		}
+		delete(p.pending, shard)
+		shardCtx, cancel := context.WithCancel(ctx)
+		p.owned[shard] = cancel
+		acquired = append(acquired, shard)
+		if p.opts.OnAcquire != nil {
+			go p.opts.OnAcquire(shardCtx, shard)
+		}
+	}
+	p.mu.Unlock()
+
+	if len(acquired) > 0 {
+		slices.Sort(acquired)
+		slog.Info("Cluster partitioner acquired shards", "partitioner", p.name, "shards", acquired)
+	}
+}
+
+func (p *Partitioner) releaseAll() {
+	p.mu.Lock()
+	released := make([]int, 0, len(p.owned))
+	for shard, cancel := range p.owned {
+		cancel()
+		released = append(released, shard)
+	}
+	clear(p.owned)
+	clear(p.pending)
+	p.mu.Unlock()
+	p.notifyReleased(released)
+}
+
+func (p *Partitioner) notifyReleased(shards []int) {
+	if len(shards) == 0 {
+		return
+	}
+	slices.Sort(shards)
+	slog.Info("Cluster partitioner released shards", "partitioner", p.name, "shards", shards)
+	if p.opts.OnRelease != nil {
+		for _, shard := range shards {
+			p.opts.OnRelease(shard)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package cluster
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func nodes(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("node-%d", i)
+	}
+	return ids
+}
+
+func assignments(p *Partitioner, members []string) []string {
+	owners := make([]string, p.opts.Shards)
+	for shard := range owners {
+		owners[shard] = p.Owner(shard, members)
+	}
+	return owners
+}
+
+func TestOwnerStable(t *testing.T) {
+	tests := []struct {
+		name    string
+		shards  int
+		members []string
+	}{
+		{"single member", 8, nodes(1)},
+		{"three members", 64, nodes(3)},
+		{"ten members", 256, nodes(10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartitioner("test", PartitionOptions{Shards: tt.shards})
+			want := assignments(p, tt.members)
+
+			// 成员顺序不影响结果
+			reversed := slices.Clone(tt.members)
+			slices.Reverse(reversed)
+			if got := assignments(p, reversed); !slices.Equal(got, want) {
+				t.Fatalf("owners depend on member order")
+			}
+
+			// 相同名称的分配器在各节点上得到相同结果
+			other := NewPartitioner("test", PartitionOptions{Shards: tt.shards})
+			if got := assignments(other, tt.members); !slices.Equal(got, want) {
+				t.Fatalf("owners differ between partitioners with the same name")
+			}
+
+			for shard, owner := range want {
+				if !slices.Contains(tt.members, owner) {
+					t.Fatalf("shard %d owned by %q, not a member", shard, owner)
+				}
+			}
+		})
+	}
+}
+
+func TestOwnerNoMembers(t *testing.T) {
+	p := NewPartitioner("test", PartitionOptions{Shards: 4})
+	if got := p.Owner(0, nil); got != "" {
+		t.Fatalf("Owner with no members = %q, want empty", got)
+	}
+}
+
+func TestOwnerMinimalMovement(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []string
+		after  []string
+	}{
+		{"join", nodes(4), nodes(5)},
+		{"leave", nodes(5), nodes(4)},
+		{"leave first", nodes(5), nodes(5)[1:]},
+		{"replace", nodes(4), append(nodes(3), "node-new")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPartitioner("movement", PartitionOptions{Shards: 512})
+			before := assignments(p, tt.before)
+			after := assignments(p, tt.after)
+
+			moved := 0
+			for shard := range before {
+				if before[shard] == after[shard] {
+					continue
+				}
+				moved++
+				// 只有离开节点的分片迁出, 只有新节点接收迁入的分片
+				if slices.Contains(tt.after, before[shard]) && slices.Contains(tt.before, after[shard]) {
+					t.Errorf("shard %d moved between surviving members %s -> %s", shard, before[shard], after[shard])
+				}
+			}
+
+			// 迁移数量约为 Shards/成员数, 留足余量避免哈希分布的偶然波动
+			limit := 2 * p.opts.Shards / min(len(tt.before), len(tt.after))
+			if moved == 0 || moved > limit {
+				t.Fatalf("moved %d shards, want 1..%d", moved, limit)
+			}
+		})
+	}
+}
+
+func TestOwnerBalance(t *testing.T) {
+	p := NewPartitioner("balance", PartitionOptions{Shards: 1024})
+	members := nodes(4)
+	counts := make(map[string]int)
+	for _, owner := range assignments(p, members) {
+		counts[owner]++
+	}
+	for _, id := range members {
+		if n := counts[id]; n < 128 || n > 384 {
+			t.Errorf("%s owns %d of 1024 shards", id, n)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package cluster
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var paused atomic.Bool
+
+// Paused 返回本节点是否已暂停参与选主和分片分配
+func Paused() bool {
+	return paused.Load()
+}
+
+// Pause 暂停本节点: 让出所有主节点且不再参与抢占, 分片分配器不再将分片分配给本节点.
+// 节点仍保持心跳, 以便在运维面板中可见.
+func Pause(ctx context.Context) error {
+	paused.Store(true)
+	var errs []error
+	for _, k := range Elections() {
+		if err := k.Resign(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("resign %s: %w", k.name, err))
+		}
+	}
+	if membershipRunning() {
+		if err := heartbeat(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	slog.Warn("Cluster node paused", "node", nodeID)
+	return errors.Join(errs...)
+}
+
+// Resume 恢复本节点参与选主和分片分配
+func Resume(ctx context.Context) error {
+	paused.Store(false)
+	for _, k := range Elections() {
+		k.mu.Lock()
+		k.holdoff = time.Time{}
+		k.mu.Unlock()
+		select {
+		case k.wake <- struct{}{}:
+		default:
+		}
+	}
+	slog.Info("Cluster node resumed", "node", nodeID)
+	if membershipRunning() {
+		return heartbeat(ctx)
+	}
+	return nil
+}
+
+// ElectionStatus 是一个选举的状态
+type ElectionStatus struct {
+	Name           string     `json:"name"`
+	Key            string     `json:"key"`
+	Leader         string     `json:"leader,omitempty"` // database 后端只在本节点是主节点时有值
+	IsMaster       bool       `json:"is_master"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	LastTransition *time.Time `json:"last_transition,omitempty"` // 本节点最近一次当选或降级的时间
+	Error          string     `json:"error,omitempty"`
+}
+
+// Status 返回选举的当前状态
+func (k *Election) Status(ctx context.Context) ElectionStatus {
+	k.mu.Lock()
+	st := ElectionStatus{
+		Name:     k.name,
+		Key:      k.key,
+		IsMaster: k.isRunNode,
+	}
+	if !k.transition.IsZero() {
+		t := k.transition
+		st.LastTransition = &t
+	}
+	k.mu.Unlock()
+
+	leader, expireAt, err := k.getBackend().Leader(ctx, k.key)
+	if err != nil {
+		st.Error = err.Error()
+	}
+	st.Leader = leader
+	if !expireAt.IsZero() {
+		st.LeaseExpiresAt = &expireAt
+	}
+	return st
+}
+
+// Status 是本节点看到的集群状态
+type Status struct {
+	Node         string           `json:"node"`
+	Role         string           `json:"role"` // master: 至少是一个选举的主节点; follower; paused
+	Paused       bool             `json:"paused"`
+	Elections    []ElectionStatus `json:"elections"`
+	Members      []Member         `json:"members"`
+	MembersError string           `json:"members_error,omitempty"`
+}
+
+// GetStatus 返回集群状态
+func GetStatus(ctx context.Context) *Status {
+	st := &Status{
+		Node:      nodeID,
+		Role:      "follower",
+		Paused:    Paused(),
+		Elections: []ElectionStatus{},
+		Members:   []Member{},
+	}
+	for _, k := range Elections() {
+		es := k.Status(ctx)
+		if es.IsMaster {
+			st.Role = "master"
+		}
+		st.Elections = append(st.Elections, es)
+	}
+	sort.Slice(st.Elections, func(i, j int) bool {
+		return st.Elections[i].Name < st.Elections[j].Name
+	})
+	if st.Paused {
+		st.Role = "paused"
+	}
+
+	members, err := Members(ctx)
+	if err != nil {
+		st.MembersError = err.Error()
+	} else if members != nil {
+		st.Members = members
+	}
+	return st
+}
+
+// HandlerOptions 是 Handler 的选项
+type HandlerOptions struct {
+	// Token 非空时, POST 请求需要携带 Authorization: Bearer <Token>
+	Token string
+	// Authorize 自定义 POST 请求的鉴权, 优先于 Token. 两者都未设置时拒绝所有 POST 请求.
+	Authorize func(r *http.Request) bool
+}
+
+// Handler 返回集群状态和控制接口:
+//
+//	GET  .../          集群状态 (JSON)
+//	POST .../resign    让出主节点, 可用 ?election=name 指定选举, 默认全部
+//	POST .../pause     暂停本节点
+//	POST .../resume    恢复本节点
+//
+// 例如 mux.Handle("/admin/cluster/", http.StripPrefix("/admin/cluster", cluster.Handler(opts))).
+// 请求由负载均衡分发到的节点执行, 针对特定节点操作时需直接访问该节点.
+func Handler(opts HandlerOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			writeJSON(w, http.StatusOK, GetStatus(r.Context()))
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorized(r, &opts) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		var err error
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		switch {
+		case strings.HasSuffix(path, "/resign"):
+			name := r.URL.Query().Get("election")
+			if name != "" && GetElection(name) == nil {
+				http.Error(w, "unknown election: "+name, http.StatusNotFound)
+				return
+			}
+			err = resign(r.Context(), name)
+		case strings.HasSuffix(path, "/pause"):
+			err = Pause(r.Context())
+		case strings.HasSuffix(path, "/resume"):
+			err = Resume(r.Context())
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("Cluster control action failed", "path", r.URL.Path, "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, GetStatus(r.Context()))
+	})
+}
+
+func authorized(r *http.Request, opts *HandlerOptions) bool {
+	if opts.Authorize != nil {
+		return opts.Authorize(r)
+	}
+	if opts.Token == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(opts.Token)) == 1
+}
+
+func resign(ctx context.Context, name string) error {
+	if name == "" {
+		var errs []error
+		for _, k := range Elections() {
+			errs = append(errs, k.Resign(ctx))
+		}
+		return errors.Join(errs...)
+	}
+	k := GetElection(name)
+	if k == nil {
+		return fmt.Errorf("unknown election: %s", name)
+	}
+	return k.Resign(ctx)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -12,11 +12,27 @@ type InfraConfig struct {
 	DB_PASSWORD string `cfg:"DB_PASSWORD"`
 	DB_DBNAME   string `cfg:"DB_DBNAME"`
 	DB_SCHEMA   string `cfg:"DB_SCHEMA"`
+	// 分片库列表, 逗号分隔的 host:port/dbname, 类型和账号与主库相同
+	DB_SHARDS string `cfg:"DB_SHARDS"`
 
-	// Redis配置
-	RedisAddr     string `cfg:"REDIS_ADDR" default:"localhost:6379"`
-	RedisPassword string `cfg:"REDIS_PASSWORD" default:""`
-	RedisDB       int    `cfg:"REDIS_DB" default:"0"`
+	// Redis配置, REDIS_ADDR 可为逗号分隔的多个地址 (cluster 节点或 sentinel 地址)
+	RedisAddr             string `cfg:"REDIS_ADDR" default:"localhost:6379"`
+	RedisUsername         string `cfg:"REDIS_USERNAME" default:""`
+	RedisPassword         string `cfg:"REDIS_PASSWORD" default:""`
+	RedisDB               int    `cfg:"REDIS_DB" default:"0"`
+	RedisMasterName       string `cfg:"REDIS_MASTER_NAME" default:""` // 设置后使用 sentinel 模式
+	RedisSentinelUsername string `cfg:"REDIS_SENTINEL_USERNAME" default:""`
+	RedisSentinelPassword string `cfg:"REDIS_SENTINEL_PASSWORD" default:""`
+	RedisClusterMode      bool   `cfg:"REDIS_CLUSTER_MODE" default:"false"` // 单个地址时强制使用 cluster 模式
+	RedisTLS              bool   `cfg:"REDIS_TLS" default:"false"`
+	RedisTLSCAFile        string `cfg:"REDIS_TLS_CA_FILE" default:""`
+	RedisTLSSkipVerify    bool   `cfg:"REDIS_TLS_SKIP_VERIFY" default:"false"`
+	RedisTLSServerName    string `cfg:"REDIS_TLS_SERVER_NAME" default:""`   // 为空时按每个连接的地址校验证书
+	RedisPoolSize         int    `cfg:"REDIS_POOL_SIZE" default:"0"`        // 0 表示使用 go-redis 默认值
+	RedisDialTimeoutMs    int    `cfg:"REDIS_DIAL_TIMEOUT_MS" default:"0"`  // 0 表示使用 go-redis 默认值
+	RedisReadTimeoutMs    int    `cfg:"REDIS_READ_TIMEOUT_MS" default:"0"`  // 0 表示使用 go-redis 默认值
+	RedisWriteTimeoutMs   int    `cfg:"REDIS_WRITE_TIMEOUT_MS" default:"0"` // 0 表示使用 go-redis 默认值
+	RedisKeyPrefix        string `cfg:"REDIS_KEY_PREFIX" default:""`        // 多个应用共用 redis 时区分各自的 key
 
 	// 邮件配置
 	SendMail struct {
@@ -36,6 +52,16 @@ type InfraConfig struct {
 		Default string `cfg:"DEFAULT" default:"project"`
 	} `cfg:"ASYNQ_NAME"`
 
+	// 事件总线配置
+	EventBus struct {
+		MaxLen int64 `cfg:"MAX_LEN" default:"100000"` // 每个 topic 的 stream 保留的近似条数
+	} `cfg:"EVENTBUS"`
+
+	// 集群配置
+	Cluster struct {
+		Backend string `cfg:"BACKEND" default:"redis"` // 选主后端: redis, database 或 memory
+	} `cfg:"CLUSTER"`
+
 	// 存储配置
 	PublicStorage  StorageInstanceConfig `cfg:"STORAGE_PUBLIC"`
 	PrivateStorage StorageInstanceConfig `cfg:"STORAGE_PRIVATE"`
@@ -14,7 +14,8 @@ import (
 )
 
 var (
-	db *gorm.DB
+	db     *gorm.DB
+	dbInfo DbInfo
 )
 
 func Database() *gorm.DB {
@@ -33,19 +34,17 @@ type DbInfo struct {
 
 var gormConfig *gorm.Config
 
-func connectDatabase(params DbInfo) error {
+func connectDatabase(params DbInfo) (err error) {
+	db, err = openDatabase(params)
+	return err
+}
+
+func openDatabase(params DbInfo) (*gorm.DB, error) {
 	var driver gorm.Dialector
-	var err error
 
 	switch strings.ToLower(params.DbType) {
 	case "pgsql", "postgresql":
-		// PostgreSQL DSN format: host=localhost user=gorm password=gorm dbname=gorm port=9920 sslmode=disable TimeZone=Asia/Shanghai
-		dbInfo := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=%s",
-			params.DbHost, params.DbUser, params.DbPassword, params.DbName, params.DbPort, config.Config.DefaultTimezone)
-		if params.DbSchema != "" {
-			dbInfo += fmt.Sprintf(" search_path=%s", params.DbSchema)
-		}
-		driver = postgres.Open(dbInfo)
+		driver = postgres.Open(postgresDSN(params))
 	case "mysql":
 		// MySQL DSN format: user:pass@tcp(127.0.0.1:3306)/dbname?charset=utf8mb4&parseTime=True&loc=Local
 		dbInfo := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
@@ -53,15 +52,33 @@ func connectDatabase(params DbInfo) error {
 		driver = mysql.Open(dbInfo)
 	default:
 		slog.Error("unsupported database type", "type", params.DbType)
-		return fmt.Errorf("unsupported database type: %s", params.DbType)
+		return nil, fmt.Errorf("unsupported database type: %s", params.DbType)
 	}
 
-	db, err = gorm.Open(driver, gormConfig)
+	conn, err := gorm.Open(driver, gormConfig)
 	if err != nil {
 		slog.Error("sql.Open failed", "error", err)
-		return err
+		return nil, err
 	}
-	return nil
+	return conn, nil
+}
+
+func isPostgres(params DbInfo) bool {
+	switch strings.ToLower(params.DbType) {
+	case "pgsql", "postgresql":
+		return true
+	}
+	return false
+}
+
+func postgresDSN(params DbInfo) string {
+	// PostgreSQL DSN format: host=localhost user=gorm password=gorm dbname=gorm port=9920 sslmode=disable TimeZone=Asia/Shanghai
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable TimeZone=%s",
+		params.DbHost, params.DbUser, params.DbPassword, params.DbName, params.DbPort, config.Config.DefaultTimezone)
+	if params.DbSchema != "" {
+		dsn += fmt.Sprintf(" search_path=%s", params.DbSchema)
+	}
+	return dsn
 }
 
 func initDBConfig() {
@@ -84,6 +101,10 @@ func Start() error {
 	params.DbPassword = config.Config.DB_PASSWORD
 	params.DbName = config.Config.DB_DBNAME
 	params.DbSchema = config.Config.DB_SCHEMA
+	dbInfo = params
 
-	return connectDatabase(params)
+	if err := connectDatabase(params); err != nil {
+		return err
+	}
+	return initShards(params)
 }
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+var ErrListenUnsupported = errors.New("database: LISTEN/NOTIFY requires a PostgreSQL database")
+
+const (
+	listenMinBackoff = 500 * time.Millisecond
+	listenMaxBackoff = 30 * time.Second
+)
+
+// Notification 是从 PostgreSQL 通道收到的一条通知
+type Notification struct {
+	Channel string
+	Payload string
+	PID     uint32 // 发送通知的后端进程id
+}
+
+// Decode 将 JSON 格式的 payload 解析到 v
+func (n *Notification) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(n.Payload), v)
+}
+
+// Listen 在独立连接上 LISTEN 指定通道, 通知通过返回的 channel 投递.
+// 连接断开后会按指数退避重连并重新 LISTEN; ctx 结束时关闭返回的 channel.
+func Listen(ctx context.Context, channel string) (<-chan *Notification, error) {
+	if !isPostgres(dbInfo) {
+		return nil, ErrListenUnsupported
+	}
+
+	// 首次连接失败直接返回错误, 方便调用方发现配置问题
+	conn, err := listenConnect(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *Notification, 64)
+	go listenLoop(ctx, conn, channel, ch)
+	return ch, nil
+}
+
+func listenConnect(ctx context.Context, channel string) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, postgresDSN(dbInfo))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
+
+func listenLoop(ctx context.Context, conn *pgx.Conn, channel string, ch chan<- *Notification) {
+	defer close(ch)
+	backoff := listenMinBackoff
+
+	for {
+		if conn == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			var err error
+			conn, err = listenConnect(ctx, channel)
+			if err != nil {
+				slog.Warn("database listen reconnect failed", "channel", channel, "error", err, "backoff", backoff)
+				backoff = min(backoff*2, listenMaxBackoff)
+				continue
+			}
+			slog.Info("database listen reconnected", "channel", channel)
+			backoff = listenMinBackoff
+		}
+
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			conn.Close(context.Background())
+			conn = nil
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("database listen connection lost", "channel", channel, "error", err)
+			continue
+		}
+
+		select {
+		case ch <- &Notification{Channel: n.Channel, Payload: n.Payload, PID: n.PID}:
+		case <-ctx.Done():
+			conn.Close(context.Background())
+			return
+		}
+	}
+}
+
+// Notify 通过 pg_notify 发送通知; 传入事务 tx 时, 通知会在事务提交后才被投递
+func Notify(tx *gorm.DB, channel, payload string) error {
+	if !isPostgres(dbInfo) {
+		return ErrListenUnsupported
+	}
+	if tx == nil {
+		tx = db
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
+
+// NotifyJSON 将 v 编码为 JSON 后通过 Notify 发送
+func NotifyJSON(tx *gorm.DB, channel string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return Notify(tx, channel, string(payload))
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+)
+
+// ErrNotStarted 表示尚未调用 database.Start
+var ErrNotStarted = errors.New("database: not started")
+
+// SessionLock 是数据库会话级的锁, 持有期间独占一个连接, 连接断开时数据库自动释放锁.
+// PostgreSQL 使用 pg_try_advisory_lock, MySQL 使用 GET_LOCK.
+type SessionLock struct {
+	name     string
+	conn     *sql.Conn
+	postgres bool
+}
+
+// TryLock 尝试获取会话锁, 已被其他会话持有时返回 nil, false, nil
+func TryLock(ctx context.Context, name string) (*SessionLock, bool, error) {
+	if db == nil {
+		return nil, false, ErrNotStarted
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	l := &SessionLock{name: name, conn: conn, postgres: isPostgres(dbInfo)}
+	var ok bool
+	if l.postgres {
+		err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", advisoryKey(name)).Scan(&ok)
+	} else {
+		var n sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", mysqlLockName(name)).Scan(&n)
+		ok = n.Valid && n.Int64 == 1
+	}
+	if err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+	return l, true, nil
+}
+
+// advisoryKey 将锁名映射为 PostgreSQL advisory lock 使用的 bigint
+func advisoryKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// mysqlLockName MySQL 锁名最长 64 个字符, 超长时使用哈希
+func mysqlLockName(name string) string {
+	if len(name) <= 64 {
+		return name
+	}
+	sum := sha1.Sum([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+func (l *SessionLock) Name() string {
+	return l.name
+}
+
+// Ping 检查持有锁的连接是否仍然可用, 连接断开意味着锁已被释放
+func (l *SessionLock) Ping(ctx context.Context) error {
+	return l.conn.PingContext(ctx)
+}
+
+// Unlock 释放锁并归还连接
+func (l *SessionLock) Unlock(ctx context.Context) error {
+	var err error
+	if l.postgres {
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", advisoryKey(l.name))
+	} else {
+		_, err = l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", mysqlLockName(l.name))
+	}
+	// 释放失败时丢弃连接而不是放回连接池, 由数据库在会话结束时释放锁
+	if err != nil {
+		l.conn.Raw(func(driverConn interface{}) error {
+			return driver.ErrBadConn
+		})
+	}
+	l.conn.Close()
+	return err
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/flaboy/aira-core/pkg/config"
+
+	"gorm.io/gorm"
+)
+
+var shards *ShardSet
+
+// Shards 返回由 DB_SHARDS 配置的默认分片集合, 未配置时为 nil
+func Shards() *ShardSet {
+	return shards
+}
+
+// ShardKeyFunc 将分片键映射到 [0, n) 中的一个分片下标, 超出范围时 Index 返回错误
+type ShardKeyFunc func(key interface{}, n int) int
+
+// ShardKeyer 由按分片存储的模型实现, 返回其分片键 (如租户id、用户id)
+type ShardKeyer interface {
+	ShardKey() interface{}
+}
+
+// HashShardKey 是默认的分片函数, 对分片键做 FNV-1a 哈希后取模
+func HashShardKey(key interface{}, n int) int {
+	h := fnv.New64a()
+	h.Write([]byte(fmt.Sprint(key)))
+	return int(h.Sum64() % uint64(n))
+}
+
+// ShardSet 是一组按分片键路由的数据库连接
+type ShardSet struct {
+	shards  []*gorm.DB
+	keyFunc ShardKeyFunc
+}
+
+// NewShardSet 连接 infos 中的每个数据库; keyFunc 为 nil 时使用 HashShardKey
+func NewShardSet(infos []DbInfo, keyFunc ShardKeyFunc) (*ShardSet, error) {
+	if len(infos) == 0 {
+		return nil, errors.New("database: shard set requires at least one database")
+	}
+	if gormConfig == nil {
+		initDBConfig()
+	}
+	if keyFunc == nil {
+		keyFunc = HashShardKey
+	}
+
+	set := &ShardSet{
+		shards:  make([]*gorm.DB, 0, len(infos)),
+		keyFunc: keyFunc,
+	}
+	for i, info := range infos {
+		conn, err := openDatabase(info)
+		if err != nil {
+			set.Close()
+			return nil, fmt.Errorf("database: connect shard %d failed: %w", i, err)
+		}
+		set.shards = append(set.shards, conn)
+	}
+	return set, nil
+}
+
+// Len 返回分片数量
+func (s *ShardSet) Len() int {
+	return len(s.shards)
+}
+
+// Index 返回分片键所在的分片下标
+func (s *ShardSet) Index(key interface{}) (int, error) {
+	idx := s.keyFunc(key, len(s.shards))
+	if idx < 0 || idx >= len(s.shards) {
+		return 0, fmt.Errorf("database: shard key func returned %d for %d shards", idx, len(s.shards))
+	}
+	return idx, nil
+}
+
+// Shard 返回分片键所在的数据库连接. 分片函数返回的下标越界时, 返回的连接带有该错误,
+// 之后的查询不会执行并返回该错误.
+func (s *ShardSet) Shard(key interface{}) *gorm.DB {
+	idx, err := s.Index(key)
+	if err != nil {
+		db := s.shards[0].Session(&gorm.Session{NewDB: true})
+		db.AddError(err)
+		return db
+	}
+	return s.shards[idx]
+}
+
+// For 返回 record 所在的数据库连接
+func (s *ShardSet) For(record ShardKeyer) *gorm.DB {
+	return s.Shard(record.ShardKey())
+}
+
+// At 返回指定下标的数据库连接
+func (s *ShardSet) At(idx int) *gorm.DB {
+	return s.shards[idx]
+}
+
+// Each 在所有分片上并发执行 fn, 返回合并后的错误
+func (s *ShardSet) Each(ctx context.Context, fn func(ctx context.Context, idx int, db *gorm.DB) error) error {
+	errs := make([]error, len(s.shards))
+	var wg sync.WaitGroup
+	for i, conn := range s.shards {
+		wg.Add(1)
+		go func(i int, conn *gorm.DB) {
+			defer wg.Done()
+			if err := fn(ctx, i, conn.WithContext(ctx)); err != nil {
+				errs[i] = fmt.Errorf("shard %d: %w", i, err)
+			}
+		}(i, conn)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// FanOut 在所有分片上并发执行查询并按分片顺序合并结果, 供管理后台等跨分片场景使用
+func FanOut[T any](ctx context.Context, s *ShardSet, query func(db *gorm.DB) ([]T, error)) ([]T, error) {
+	parts := make([][]T, s.Len())
+	err := s.Each(ctx, func(ctx context.Context, idx int, db *gorm.DB) error {
+		rows, err := query(db)
+		parts[idx] = rows
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []T
+	for _, rows := range parts {
+		result = append(result, rows...)
+	}
+	return result, nil
+}
+
+// Migrate 依次在每个分片上执行 fn, 任一分片失败即停止, 以免各分片结构不一致扩散
+func (s *ShardSet) Migrate(ctx context.Context, fn func(db *gorm.DB) error) error {
+	for i, conn := range s.shards {
+		slog.Info("migrating shard", "shard", i)
+		if err := fn(conn.WithContext(ctx)); err != nil {
+			return fmt.Errorf("database: migrate shard %d failed: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// AutoMigrate 在每个分片上执行 gorm AutoMigrate
+func (s *ShardSet) AutoMigrate(ctx context.Context, models ...interface{}) error {
+	return s.Migrate(ctx, func(db *gorm.DB) error {
+		return db.AutoMigrate(models...)
+	})
+}
+
+// Close 关闭所有分片连接
+func (s *ShardSet) Close() error {
+	var errs []error
+	for _, conn := range s.shards {
+		sqlDB, err := conn.DB()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, sqlDB.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func initShards(base DbInfo) error {
+	infos, err := parseShards(config.Config.DB_SHARDS, base)
+	if err != nil || len(infos) == 0 {
+		return err
+	}
+	shards, err = NewShardSet(infos, nil)
+	return err
+}
+
+// parseShards 解析 DB_SHARDS, 格式: host:port/dbname,host:port/dbname
+func parseShards(spec string, base DbInfo) ([]DbInfo, error) {
+	var infos []DbInfo
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		info := base
+		addr, name, ok := strings.Cut(item, "/")
+		if ok && name != "" {
+			info.DbName = name
+		}
+		host, port, ok := strings.Cut(addr, ":")
+		info.DbHost = host
+		if ok {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("database: invalid shard port in %q", item)
+			}
+			info.DbPort = p
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
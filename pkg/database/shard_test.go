@@ -0,0 +1,132 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flaboy/aira-core/pkg/config"
+
+	"gorm.io/gorm"
+)
+
+func TestParseShards(t *testing.T) {
+	base := DbInfo{
+		DbType:     "pgsql",
+		DbHost:     "primary",
+		DbPort:     5432,
+		DbUser:     "app",
+		DbPassword: "secret",
+		DbName:     "app",
+		DbSchema:   "public",
+	}
+	with := func(host string, port int, name string) DbInfo {
+		info := base
+		info.DbHost, info.DbPort, info.DbName = host, port, name
+		return info
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    []DbInfo
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"only separators", " , ,", nil, false},
+		{"host port and name", "db1:5433/app_1", []DbInfo{with("db1", 5433, "app_1")}, false},
+		{"host only keeps base port and name", "db1", []DbInfo{with("db1", 5432, "app")}, false},
+		{"host and name", "db1/app_1", []DbInfo{with("db1", 5432, "app_1")}, false},
+		{"empty name keeps base name", "db1:5433/", []DbInfo{with("db1", 5433, "app")}, false},
+		{"multiple with spaces", " db1:5433/app_1 , db2:5434/app_2 ", []DbInfo{
+			with("db1", 5433, "app_1"),
+			with("db2", 5434, "app_2"),
+		}, false},
+		{"skips empty items", "db1/app_1,,db2/app_2,", []DbInfo{
+			with("db1", 5432, "app_1"),
+			with("db2", 5432, "app_2"),
+		}, false},
+		{"invalid port", "db1:abc/app_1", nil, true},
+		{"invalid port in later item", "db1/app_1,db2:/app_2", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseShards(tt.spec, base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseShards(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseShards(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShardDSN(t *testing.T) {
+	old := config.Config
+	config.Config = &config.InfraConfig{DefaultTimezone: "UTC"}
+	t.Cleanup(func() { config.Config = old })
+
+	base := DbInfo{DbType: "pgsql", DbHost: "primary", DbPort: 5432, DbUser: "app", DbName: "app", DbSchema: "tenant"}
+	infos, err := parseShards("db1:5433/app_1,db2", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		info DbInfo
+		want []string
+	}{
+		{infos[0], []string{"host=db1 ", "port=5433 ", "dbname=app_1 ", "user=app ", "search_path=tenant"}},
+		{infos[1], []string{"host=db2 ", "port=5432 ", "dbname=app ", "user=app ", "search_path=tenant"}},
+	}
+	for _, tt := range tests {
+		if !isPostgres(tt.info) {
+			t.Fatalf("shard %s is not treated as postgres", tt.info.DbHost)
+		}
+		dsn := postgresDSN(tt.info)
+		for _, part := range tt.want {
+			if !strings.Contains(dsn, part) {
+				t.Errorf("DSN %q does not contain %q", dsn, part)
+			}
+		}
+	}
+}
+
+func TestShardIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyFunc ShardKeyFunc
+		want    int
+		wantErr bool
+	}{
+		{"in range", func(key interface{}, n int) int { return n - 1 }, 3, false},
+		{"negative", func(key interface{}, n int) int { return -1 }, 0, true},
+		{"too large", func(key interface{}, n int) int { return n }, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ShardSet{shards: make([]*gorm.DB, 4), keyFunc: tt.keyFunc}
+			got, err := s.Index("key")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("Index() = %d, %v; want %d, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHashShardKey(t *testing.T) {
+	for _, key := range []interface{}{"tenant-1", 42, uint64(7), ""} {
+		idx := HashShardKey(key, 8)
+		if idx < 0 || idx >= 8 {
+			t.Fatalf("HashShardKey(%v, 8) = %d, out of range", key, idx)
+		}
+		if idx != HashShardKey(key, 8) {
+			t.Fatalf("HashShardKey(%v) is not stable", key)
+		}
+	}
+	// 数字和字符串形式的同一个 id 落在同一分片
+	if HashShardKey(42, 8) != HashShardKey("42", 8) {
+		t.Fatal("HashShardKey(42) and HashShardKey(\"42\") differ")
+	}
+}
@@ -0,0 +1,356 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/config"
+	"github.com/flaboy/aira-core/pkg/redis"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// 基于 redis stream 的可靠事件总线: 消费组订阅, 处理成功后 ack,
+// 崩溃消费者未 ack 的消息由其他消费者通过 XAUTOCLAIM 接管, 超过最大投递次数后转入死信 stream.
+
+// Event 是从 stream 中读取的一条事件
+type Event struct {
+	ID         string
+	Topic      string
+	Data       json.RawMessage
+	Time       time.Time
+	Deliveries int64 // 已投递次数, 首次投递为 1
+}
+
+// Decode 将事件内容解析到 v
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Handler 处理一条事件, 返回 nil 时消息被 ack, 否则留在 pending 列表中等待重新投递
+type Handler func(ctx context.Context, e *Event) error
+
+func streamKey(topic string) string {
+	return redis.Key("stream", topic)
+}
+
+func deadKey(topic string) string {
+	return redis.Key("stream", topic, "dead")
+}
+
+// Emit 将事件编码为 JSON 写入 topic 对应的 stream, 返回消息id
+func Emit(ctx context.Context, topic string, event interface{}) (string, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	args := &goredis.XAddArgs{
+		Stream: streamKey(topic),
+		Values: map[string]interface{}{
+			"data": data,
+			"ts":   time.Now().UnixMilli(),
+		},
+	}
+	if config.Config.EventBus.MaxLen > 0 {
+		args.MaxLen = config.Config.EventBus.MaxLen
+		args.Approx = true
+	}
+	return redis.RedisClient.XAdd(ctx, args).Result()
+}
+
+// Trim 将 topic 的 stream 裁剪到大约 maxLen 条
+func Trim(ctx context.Context, topic string, maxLen int64) error {
+	return redis.RedisClient.XTrimMaxLenApprox(ctx, streamKey(topic), maxLen, 0).Err()
+}
+
+// TrimBefore 删除 topic 中早于 t 的事件
+func TrimBefore(ctx context.Context, topic string, t time.Time) error {
+	minID := strconv.FormatInt(t.UnixMilli(), 10) + "-0"
+	return redis.RedisClient.XTrimMinIDApprox(ctx, streamKey(topic), minID, 0).Err()
+}
+
+// DeadLetters 读取 topic 死信 stream 中最多 count 条事件
+func DeadLetters(ctx context.Context, topic string, count int64) ([]*Event, error) {
+	msgs, err := redis.RedisClient.XRangeN(ctx, deadKey(topic), "-", "+", count).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*Event, 0, len(msgs))
+	for _, msg := range msgs {
+		events = append(events, newEvent(topic, msg))
+	}
+	return events, nil
+}
+
+type Options struct {
+	Consumer        string        // 消费者名称, 默认 hostname-pid
+	FromStart       bool          // 新建消费组时从 stream 开头消费, 默认只消费之后的新事件
+	BatchSize       int64         // 每次读取的条数, 默认 10
+	Block           time.Duration // 阻塞读取的最长时间, 默认 5s
+	MinIdle         time.Duration // pending 消息空闲多久后可被接管, 默认 1 分钟
+	ReclaimInterval time.Duration // 检查可接管消息的间隔, 默认 30s
+	MaxDeliveries   int64         // 超过该投递次数后转入死信, 默认 5
+}
+
+func (o *Options) setDefaults() {
+	if o.Consumer == "" {
+		host, _ := os.Hostname()
+		o.Consumer = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 10
+	}
+	if o.Block <= 0 {
+		o.Block = 5 * time.Second
+	}
+	if o.MinIdle <= 0 {
+		o.MinIdle = time.Minute
+	}
+	if o.ReclaimInterval <= 0 {
+		o.ReclaimInterval = 30 * time.Second
+	}
+	if o.MaxDeliveries <= 0 {
+		o.MaxDeliveries = 5
+	}
+}
+
+// Subscriber 是消费组中的一个消费者
+type Subscriber struct {
+	topic   string
+	group   string
+	stream  string
+	handler Handler
+	opts    Options
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Subscribe 以消费组 group 订阅 topic, 消费组不存在时自动创建.
+// 同一消费组内的多个消费者分摊事件, 不同消费组各自收到全部事件.
+// ctx 结束或调用 Close 时停止消费.
+func Subscribe(ctx context.Context, topic, group string, handler Handler, opts *Options) (*Subscriber, error) {
+	s := &Subscriber{
+		topic:   topic,
+		group:   group,
+		stream:  streamKey(topic),
+		handler: handler,
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	s.opts.setDefaults()
+
+	start := "$"
+	if s.opts.FromStart {
+		start = "0"
+	}
+	err := redis.RedisClient.XGroupCreateMkStream(ctx, s.stream, group, start).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(2)
+	go s.readLoop(ctx)
+	go s.reclaimLoop(ctx)
+	return s, nil
+}
+
+// Close 停止消费, 等待正在处理的事件完成
+func (s *Subscriber) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Subscriber) readLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	// 先处理本消费者上次退出时未 ack 的消息, 再读取新消息
+	lastID := "0"
+	for ctx.Err() == nil {
+		streams, err := redis.RedisClient.XReadGroup(ctx, &goredis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.opts.Consumer,
+			Streams:  []string{s.stream, lastID},
+			Count:    s.opts.BatchSize,
+			Block:    s.opts.Block,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
+			slog.Error("eventbus read failed", "topic", s.topic, "group", s.group, "error", err)
+			sleep(ctx, time.Second)
+			continue
+		}
+
+		var msgs []goredis.XMessage
+		for _, st := range streams {
+			msgs = append(msgs, st.Messages...)
+		}
+		if lastID != ">" && len(msgs) == 0 {
+			lastID = ">"
+			continue
+		}
+		for _, msg := range msgs {
+			if lastID != ">" {
+				lastID = msg.ID
+				s.handleReclaimed(ctx, msg)
+			} else {
+				s.handle(ctx, msg, 1)
+			}
+		}
+	}
+}
+
+func (s *Subscriber) reclaimLoop(ctx context.Context) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.opts.ReclaimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.reclaim(ctx)
+	}
+}
+
+// reclaim 接管其他消费者空闲超过 MinIdle 的 pending 消息
+func (s *Subscriber) reclaim(ctx context.Context) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		msgs, next, err := redis.RedisClient.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			Consumer: s.opts.Consumer,
+			MinIdle:  s.opts.MinIdle,
+			Start:    start,
+			Count:    s.opts.BatchSize,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("eventbus reclaim failed", "topic", s.topic, "group", s.group, "error", err)
+			}
+			return
+		}
+		for _, msg := range msgs {
+			s.handleReclaimed(ctx, msg)
+		}
+		if next == "0-0" || len(msgs) == 0 {
+			return
+		}
+		start = next
+	}
+}
+
+// handleReclaimed 处理重新投递的消息, 投递次数过多时转入死信
+func (s *Subscriber) handleReclaimed(ctx context.Context, msg goredis.XMessage) {
+	if msg.Values == nil {
+		// 消息已被裁剪, 只需 ack
+		s.ack(ctx, msg.ID)
+		return
+	}
+
+	deliveries := int64(1)
+	pending, err := redis.RedisClient.XPendingExt(ctx, &goredis.XPendingExtArgs{
+		Stream: s.stream,
+		Group:  s.group,
+		Start:  msg.ID,
+		End:    msg.ID,
+		Count:  1,
+	}).Result()
+	if err == nil && len(pending) == 1 {
+		deliveries = pending[0].RetryCount
+	}
+
+	if deliveries > s.opts.MaxDeliveries {
+		s.deadLetter(ctx, msg, deliveries)
+		return
+	}
+	s.handle(ctx, msg, deliveries)
+}
+
+func (s *Subscriber) handle(ctx context.Context, msg goredis.XMessage, deliveries int64) {
+	event := newEvent(s.topic, msg)
+	event.Deliveries = deliveries
+
+	if err := s.call(ctx, event); err != nil {
+		slog.Error("eventbus handler failed", "topic", s.topic, "group", s.group, "id", msg.ID,
+			"deliveries", deliveries, "error", err)
+		return
+	}
+	s.ack(ctx, msg.ID)
+}
+
+func (s *Subscriber) call(ctx context.Context, event *Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			debug.PrintStack()
+		}
+	}()
+	return s.handler(ctx, event)
+}
+
+// ack 不随订阅 ctx 取消, 关闭期间处理完成的事件也能确认, 不会被重新投递
+func (s *Subscriber) ack(ctx context.Context, id string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := redis.RedisClient.XAck(ctx, s.stream, s.group, id).Err(); err != nil {
+		slog.Error("eventbus ack failed", "topic", s.topic, "group", s.group, "id", id, "error", err)
+	}
+}
+
+func (s *Subscriber) deadLetter(ctx context.Context, msg goredis.XMessage, deliveries int64) {
+	values := map[string]interface{}{
+		"id":         msg.ID,
+		"group":      s.group,
+		"deliveries": deliveries,
+	}
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	ctx = context.WithoutCancel(ctx)
+	err := redis.RedisClient.XAdd(ctx, &goredis.XAddArgs{
+		Stream: deadKey(s.topic),
+		Values: values,
+	}).Err()
+	if err != nil {
+		slog.Error("eventbus dead-letter failed", "topic", s.topic, "group", s.group, "id", msg.ID, "error", err)
+		return
+	}
+	slog.Warn("eventbus event dead-lettered", "topic", s.topic, "group", s.group, "id", msg.ID, "deliveries", deliveries)
+	s.ack(ctx, msg.ID)
+}
+
+func newEvent(topic string, msg goredis.XMessage) *Event {
+	event := &Event{ID: msg.ID, Topic: topic}
+	if data, ok := msg.Values["data"].(string); ok {
+		event.Data = json.RawMessage(data)
+	}
+	if ts, ok := msg.Values["ts"].(string); ok {
+		if ms, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			event.Time = time.UnixMilli(ms)
+		}
+	}
+	return event
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
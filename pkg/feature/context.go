@@ -0,0 +1,92 @@
+package feature
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hibiken/asynq"
+)
+
+type subjectKey struct{}
+
+// WithSubject 将 Subject 放入 context, 之后可以直接调用 Enabled
+func WithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+func SubjectFromContext(ctx context.Context) Subject {
+	s, _ := ctx.Value(subjectKey{}).(Subject)
+	return s
+}
+
+// Middleware 将 subjectFunc 返回的 Subject 放入请求 context:
+//
+//	mux.Handle("/", feature.Middleware(func(r *http.Request) feature.Subject {
+//		return feature.Subject{UserID: currentUser(r).ID}
+//	})(handler))
+//
+//	if feature.Enabled(r.Context(), "new-checkout") { ... }
+func Middleware(subjectFunc func(r *http.Request) Subject) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := WithSubject(r.Context(), subjectFunc(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Require 开关关闭时返回 404
+func Require(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Enabled(r.Context(), key) {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PayloadSubject 从 JSON payload 的 user_id 和 tenant_id 字段读取 Subject
+func PayloadSubject(ctx context.Context, task *asynq.Task) Subject {
+	// 使用 json.Number 保留数字id的原文, float64 无法精确表示超过 2^53 的整数
+	var p map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(task.Payload()))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		return Subject{}
+	}
+	return Subject{
+		UserID:   stringField(p["user_id"]),
+		TenantID: stringField(p["tenant_id"]),
+	}
+}
+
+func stringField(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// TaskHandler 将 Subject 放入任务 context, subjectFunc 为 nil 时使用 PayloadSubject:
+//
+//	tasklib.Consumer("report:build", feature.TaskHandler(handler, nil))
+func TaskHandler(handler asynq.Handler, subjectFunc func(ctx context.Context, task *asynq.Task) Subject) asynq.Handler {
+	if subjectFunc == nil {
+		subjectFunc = PayloadSubject
+	}
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		return handler.ProcessTask(WithSubject(ctx, subjectFunc(ctx, task)), task)
+	})
+}
@@ -0,0 +1,31 @@
+package feature
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestPayloadSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Subject
+	}{
+		{"strings", `{"user_id":"u1","tenant_id":"t1"}`, Subject{UserID: "u1", TenantID: "t1"}},
+		{"numbers", `{"user_id":42,"tenant_id":7}`, Subject{UserID: "42", TenantID: "7"}},
+		{"large number", `{"user_id":9007199254740993}`, Subject{UserID: "9007199254740993"}},
+		{"missing fields", `{"other":1}`, Subject{}},
+		{"invalid json", `not json`, Subject{}},
+		{"empty", ``, Subject{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PayloadSubject(context.Background(), asynq.NewTask("t", []byte(tt.payload)))
+			if got.UserID != tt.want.UserID || got.TenantID != tt.want.TenantID {
+				t.Fatalf("PayloadSubject(%s) = %+v, want %+v", tt.payload, got, tt.want)
+			}
+		})
+	}
+}
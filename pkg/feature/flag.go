@@ -0,0 +1,89 @@
+package feature
+
+import (
+	"hash/fnv"
+	"slices"
+	"time"
+)
+
+// Flag 是一个功能开关. 判断顺序: 总开关 -> 定向规则 (第一条匹配的规则生效) -> 按比例灰度.
+type Flag struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Key         string    `gorm:"size:128;uniqueIndex" json:"key"`
+	Description string    `gorm:"size:512" json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`    // 总开关, 关闭时对所有人关闭
+	Percentage  int       `json:"percentage"` // 未命中规则时按用户(或租户)稳定哈希放量的百分比, 0-100
+	Rules       []Rule    `gorm:"serializer:json" json:"rules,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Flag) TableName() string {
+	return "feature_flags"
+}
+
+// Rule 按属性定向, 例如 {Attribute: "tenant", Values: ["t1", "t2"], Enabled: true}.
+// 内置属性 "user" 和 "tenant" 分别对应 Subject.UserID 和 Subject.TenantID.
+type Rule struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+	Enabled   bool     `json:"enabled"`
+}
+
+// Subject 是被评估的对象
+type Subject struct {
+	UserID     string
+	TenantID   string
+	Attributes map[string]string
+}
+
+func (s Subject) attribute(name string) (string, bool) {
+	switch name {
+	case "user":
+		return s.UserID, s.UserID != ""
+	case "tenant":
+		return s.TenantID, s.TenantID != ""
+	}
+	v, ok := s.Attributes[name]
+	return v, ok
+}
+
+// bucketKey 是灰度分桶使用的稳定标识, 优先使用用户id
+func (s Subject) bucketKey() string {
+	if s.UserID != "" {
+		return s.UserID
+	}
+	return s.TenantID
+}
+
+// Evaluate 判断开关对 subject 是否开启
+func (f *Flag) Evaluate(subject Subject) bool {
+	if f == nil || !f.Enabled {
+		return false
+	}
+	for _, rule := range f.Rules {
+		if v, ok := subject.attribute(rule.Attribute); ok && slices.Contains(rule.Values, v) {
+			return rule.Enabled
+		}
+	}
+	switch {
+	case f.Percentage >= 100:
+		return true
+	case f.Percentage <= 0:
+		return false
+	}
+	key := subject.bucketKey()
+	if key == "" {
+		return false
+	}
+	return bucket(f.Key, key) < f.Percentage
+}
+
+// bucket 将 subject 稳定地映射到 [0, 100), 不同开关的分桶互相独立
+func bucket(flagKey, subjectKey string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flagKey))
+	h.Write([]byte{0})
+	h.Write([]byte(subjectKey))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,156 @@
+package feature
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    *Flag
+		subject Subject
+		want    bool
+	}{
+		{"nil flag", nil, Subject{UserID: "u1"}, false},
+		{"master switch off", &Flag{Key: "f", Percentage: 100}, Subject{UserID: "u1"}, false},
+		{"master switch off ignores rules", &Flag{
+			Key:   "f",
+			Rules: []Rule{{Attribute: "user", Values: []string{"u1"}, Enabled: true}},
+		}, Subject{UserID: "u1"}, false},
+		{"full rollout", &Flag{Key: "f", Enabled: true, Percentage: 100}, Subject{}, true},
+		{"zero rollout", &Flag{Key: "f", Enabled: true}, Subject{UserID: "u1"}, false},
+		{"user rule enables", &Flag{
+			Key:     "f",
+			Enabled: true,
+			Rules:   []Rule{{Attribute: "user", Values: []string{"u1", "u2"}, Enabled: true}},
+		}, Subject{UserID: "u2"}, true},
+		{"user rule disables before rollout", &Flag{
+			Key:        "f",
+			Enabled:    true,
+			Percentage: 100,
+			Rules:      []Rule{{Attribute: "user", Values: []string{"u1"}, Enabled: false}},
+		}, Subject{UserID: "u1"}, false},
+		{"first matching rule wins", &Flag{
+			Key:     "f",
+			Enabled: true,
+			Rules: []Rule{
+				{Attribute: "tenant", Values: []string{"t1"}, Enabled: false},
+				{Attribute: "user", Values: []string{"u1"}, Enabled: true},
+			},
+		}, Subject{UserID: "u1", TenantID: "t1"}, false},
+		{"later rule matches", &Flag{
+			Key:     "f",
+			Enabled: true,
+			Rules: []Rule{
+				{Attribute: "tenant", Values: []string{"t2"}, Enabled: false},
+				{Attribute: "user", Values: []string{"u1"}, Enabled: true},
+			},
+		}, Subject{UserID: "u1", TenantID: "t1"}, true},
+		{"custom attribute", &Flag{
+			Key:     "f",
+			Enabled: true,
+			Rules:   []Rule{{Attribute: "plan", Values: []string{"pro"}, Enabled: true}},
+		}, Subject{UserID: "u1", Attributes: map[string]string{"plan": "pro"}}, true},
+		{"empty user does not match rule", &Flag{
+			Key:     "f",
+			Enabled: true,
+			Rules:   []Rule{{Attribute: "user", Values: []string{""}, Enabled: true}},
+		}, Subject{}, false},
+		{"no rule matches falls back to rollout", &Flag{
+			Key:        "f",
+			Enabled:    true,
+			Percentage: 100,
+			Rules:      []Rule{{Attribute: "user", Values: []string{"u9"}, Enabled: false}},
+		}, Subject{UserID: "u1"}, true},
+		{"partial rollout needs a subject", &Flag{Key: "f", Enabled: true, Percentage: 99}, Subject{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flag.Evaluate(tt.subject); got != tt.want {
+				t.Fatalf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePercentage(t *testing.T) {
+	tests := []struct {
+		percentage int
+	}{
+		{1}, {10}, {50}, {90},
+	}
+	const subjects = 10000
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d%%", tt.percentage), func(t *testing.T) {
+			f := &Flag{Key: "rollout", Enabled: true, Percentage: tt.percentage}
+			enabled := 0
+			for i := 0; i < subjects; i++ {
+				s := Subject{UserID: fmt.Sprintf("user-%d", i)}
+				got := f.Evaluate(s)
+				if got != f.Evaluate(s) {
+					t.Fatalf("Evaluate is not stable for %s", s.UserID)
+				}
+				if got != (bucket(f.Key, s.UserID) < tt.percentage) {
+					t.Fatalf("Evaluate disagrees with bucket for %s", s.UserID)
+				}
+				if got {
+					enabled++
+				}
+			}
+			want := subjects * tt.percentage / 100
+			if diff := enabled - want; diff < -subjects/50 || diff > subjects/50 {
+				t.Fatalf("%d of %d enabled, want about %d", enabled, subjects, want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePercentageMonotonic(t *testing.T) {
+	// 提高比例时已开启的用户保持开启
+	low := &Flag{Key: "grow", Enabled: true, Percentage: 20}
+	high := &Flag{Key: "grow", Enabled: true, Percentage: 60}
+	for i := 0; i < 1000; i++ {
+		s := Subject{UserID: fmt.Sprintf("user-%d", i)}
+		if low.Evaluate(s) && !high.Evaluate(s) {
+			t.Fatalf("%s enabled at 20%% but not at 60%%", s.UserID)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	tests := []struct {
+		flagKey    string
+		subjectKey string
+	}{
+		{"a", "u1"},
+		{"checkout", "42"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		b := bucket(tt.flagKey, tt.subjectKey)
+		if b < 0 || b >= 100 {
+			t.Fatalf("bucket(%q, %q) = %d, out of range", tt.flagKey, tt.subjectKey, b)
+		}
+		if b != bucket(tt.flagKey, tt.subjectKey) {
+			t.Fatalf("bucket(%q, %q) is not stable", tt.flagKey, tt.subjectKey)
+		}
+	}
+
+	// 不同开关的分桶互相独立, 同一批用户不会总是同时命中
+	same := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		if (bucket("flag-a", id) < 50) == (bucket("flag-b", id) < 50) {
+			same++
+		}
+	}
+	if same > 600 || same < 400 {
+		t.Fatalf("buckets of different flags are correlated: %d of 1000 agree", same)
+	}
+
+	// 用户id与开关key拼接不产生歧义
+	if bucket("ab", "c") == bucket("a", "bc") && bucket("xy", "z") == bucket("x", "yz") {
+		t.Fatalf("bucket does not separate flag key and subject key")
+	}
+}
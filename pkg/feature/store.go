@@ -0,0 +1,278 @@
+package feature
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/database"
+	"github.com/flaboy/aira-core/pkg/redis"
+
+	goredis "github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// 变更通知频道, 消息内容为开关 key
+	changedChannel = "feature:changed"
+	// redis 中开关缓存的有效期, 同时也是各节点的兜底刷新间隔
+	cacheTTL = time.Minute
+	// 缓存哈希中的标记字段, 用于区分 "没有开关" 和 "缓存未命中"
+	loadedField = "\x00loaded"
+
+	subscribeRetryInterval = 5 * time.Second
+)
+
+// 本节点的开关快照, 评估时只读内存
+var (
+	current    atomic.Pointer[snapshot]
+	refreshing atomic.Bool
+	group      singleflight.Group
+	subMu      sync.Mutex
+	sub        *redis.Subscription
+	subTried   time.Time
+)
+
+type snapshot struct {
+	flags    map[string]*Flag
+	loadedAt time.Time
+}
+
+// 缓存哈希和代数计数器使用同一个 hash tag, 以便在同一个脚本中访问
+func cacheKey() string {
+	return redis.Key("feature", "{flags}")
+}
+
+// genKey 是开关的代数, 每次变更加一. 回填缓存时代数已变化说明读到的数据可能已过期, 放弃回填.
+func genKey() string {
+	return redis.Key("feature", "{flags}", "gen")
+}
+
+// fillScript 在代数仍为 ARGV[1] 时回填缓存; ARGV[2] 为有效期毫秒数, 之后为哈希字段和值
+var fillScript = redis.RegisterScript("feature_fill", `
+local gen = redis.call('get', KEYS[2]) or '0'
+if gen ~= ARGV[1] then
+	return 0
+end
+redis.call('del', KEYS[1])
+redis.call('hset', KEYS[1], unpack(ARGV, 3))
+redis.call('pexpire', KEYS[1], ARGV[2])
+return 1
+`)
+
+// Migrate 创建 feature_flags 表
+func Migrate() error {
+	return database.Database().AutoMigrate(&Flag{})
+}
+
+// flags 返回当前快照. 首次调用时同步加载, 之后过期时在后台刷新, 加载失败时继续使用旧快照.
+func flags(ctx context.Context) map[string]*Flag {
+	ensureListener()
+	snap := current.Load()
+	if snap == nil {
+		if err := refresh(ctx); err != nil {
+			slog.Error("feature flags load failed", "error", err)
+		}
+		if snap = current.Load(); snap == nil {
+			return nil
+		}
+	}
+	if time.Since(snap.loadedAt) >= cacheTTL && refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer refreshing.Store(false)
+			if err := refresh(context.Background()); err != nil {
+				slog.Warn("feature flags refresh failed", "error", err)
+			}
+		}()
+	}
+	return snap.flags
+}
+
+// refresh 重新加载快照, 并发调用会合并为一次加载
+func refresh(ctx context.Context) error {
+	_, err, _ := group.Do("load", func() (interface{}, error) {
+		m, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		current.Store(&snapshot{flags: m, loadedAt: time.Now()})
+		return nil, nil
+	})
+	return err
+}
+
+// reload 在数据变更后刷新快照, 不合并到变更前已开始的加载
+func reload(ctx context.Context) error {
+	group.Forget("load")
+	return refresh(ctx)
+}
+
+// load 优先从 redis 读取, 缓存未命中时从数据库加载并回填 redis
+func load(ctx context.Context) (map[string]*Flag, error) {
+	data, err := redis.RedisClient.HGetAll(ctx, cacheKey()).Result()
+	if err == nil && len(data) > 0 {
+		m := make(map[string]*Flag, len(data))
+		for key, v := range data {
+			if key == loadedField {
+				continue
+			}
+			var f Flag
+			if err := json.Unmarshal([]byte(v), &f); err != nil {
+				return nil, fmt.Errorf("decode flag %s: %w", key, err)
+			}
+			m[key] = &f
+		}
+		return m, nil
+	}
+	if err != nil {
+		slog.Warn("feature flags cache read failed, loading from database", "error", err)
+	}
+
+	// 先读代数再读数据库, 读取期间发生的变更会使回填失败
+	gen, genErr := redis.RedisClient.Get(ctx, genKey()).Result()
+	if errors.Is(genErr, redis.Nil) {
+		gen, genErr = "0", nil
+	}
+
+	var list []*Flag
+	if err := database.Database().WithContext(ctx).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	m := make(map[string]*Flag, len(list))
+	args := []interface{}{gen, cacheTTL.Milliseconds(), loadedField, "1"}
+	for _, f := range list {
+		m[f.Key] = f
+		v, err := json.Marshal(f)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, f.Key, v)
+	}
+
+	if genErr != nil {
+		return m, nil
+	}
+	if err := fillScript.Run(ctx, []string{cacheKey(), genKey()}, args...).Err(); err != nil {
+		slog.Warn("feature flags cache write failed", "error", err)
+	}
+	return m, nil
+}
+
+// ensureListener 惰性订阅变更频道, 收到变更或重连后重新加载快照
+func ensureListener() {
+	subMu.Lock()
+	defer subMu.Unlock()
+	if sub != nil || time.Since(subTried) < subscribeRetryInterval {
+		return
+	}
+	subTried = time.Now()
+
+	s, err := redis.SubscribeContext(context.Background(), changedChannel)
+	if err != nil {
+		slog.Warn("feature subscribe failed", "channel", changedChannel, "error", err)
+		return
+	}
+	sub = s
+	go listen(s)
+}
+
+func listen(s *redis.Subscription) {
+	for {
+		select {
+		case msg, ok := <-s.Messages():
+			if !ok {
+				subMu.Lock()
+				if sub == s {
+					sub = nil
+				}
+				subMu.Unlock()
+				return
+			}
+			slog.Debug("feature flag changed", "key", msg.Payload)
+		case state := <-s.States():
+			// 断线期间可能错过变更通知
+			if !state.Connected {
+				continue
+			}
+		}
+		if err := reload(context.Background()); err != nil {
+			slog.Warn("feature flags refresh failed", "error", err)
+		}
+	}
+}
+
+// Set 创建或更新开关, 并立即通知所有节点
+func Set(ctx context.Context, f *Flag) error {
+	if f.Key == "" {
+		return errors.New("feature: flag key is required")
+	}
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return fmt.Errorf("feature: percentage must be between 0 and 100, got %d", f.Percentage)
+	}
+	err := database.Database().WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description", "enabled", "percentage", "rules", "updated_at"}),
+	}).Create(f).Error
+	if err != nil {
+		return err
+	}
+	return changed(ctx, f.Key)
+}
+
+// Delete 删除开关, 之后对所有人关闭
+func Delete(ctx context.Context, key string) error {
+	if err := database.Database().WithContext(ctx).Where(&Flag{Key: key}).Delete(&Flag{}).Error; err != nil {
+		return err
+	}
+	return changed(ctx, key)
+}
+
+// changed 增加代数并清除 redis 缓存, 然后广播变更, 本节点同步刷新
+func changed(ctx context.Context, key string) error {
+	_, err := redis.RedisClient.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Incr(ctx, genKey())
+		pipe.Del(ctx, cacheKey())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := redis.PublishContext(ctx, changedChannel, key); err != nil {
+		slog.Warn("feature publish change failed", "key", key, "error", err)
+	}
+	return reload(ctx)
+}
+
+// Get 返回开关的当前配置, 不存在时返回 nil
+func Get(ctx context.Context, key string) *Flag {
+	return flags(ctx)[key]
+}
+
+// List 从数据库返回所有开关, 按 key 排序
+func List(ctx context.Context) ([]*Flag, error) {
+	var list []*Flag
+	if err := database.Database().WithContext(ctx).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list, nil
+}
+
+// Enabled 使用 context 中的 Subject 判断开关是否开启, 开关不存在时返回 false
+func Enabled(ctx context.Context, key string) bool {
+	return EnabledFor(ctx, key, SubjectFromContext(ctx))
+}
+
+// EnabledFor 判断开关对指定 subject 是否开启
+func EnabledFor(ctx context.Context, key string, subject Subject) bool {
+	return flags(ctx)[key].Evaluate(subject)
+}
@@ -0,0 +1,160 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Options struct {
+	Header  string        // 请求头名称, 默认 Idempotency-Key
+	TTL     time.Duration // 结果保留时长, 默认 24 小时
+	LockTTL time.Duration // 处理中状态的最长保留时间, 默认 1 分钟
+
+	// Scope 区分不同接口的 key 空间, 默认使用 method + path
+	Scope func(r *http.Request) string
+
+	// CacheServerErrors 为 true 时 5xx 响应也会被保存, 默认释放 key 以便客户端重试
+	CacheServerErrors bool
+}
+
+func (o *Options) setDefaults() {
+	if o.Header == "" {
+		o.Header = "Idempotency-Key"
+	}
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.LockTTL <= 0 {
+		o.LockTTL = time.Minute
+	}
+	if o.Scope == nil {
+		o.Scope = func(r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}
+	}
+}
+
+// Middleware 对带有 Idempotency-Key 头的非幂等请求 (POST/PUT/PATCH/DELETE) 去重:
+// 首次请求正常处理并保存响应; 重放时直接返回保存的响应;
+// 相同 key 处理中返回 409, 相同 key 但请求体不同返回 422.
+func Middleware(opts Options) func(http.Handler) http.Handler {
+	opts.setDefaults()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(opts.Header)
+			if key == "" || !isUnsafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scope := opts.Scope(r)
+			sum := sha256.Sum256(body)
+			rec, token, err := Reserve(r.Context(), scope, key, hex.EncodeToString(sum[:]), opts.LockTTL)
+			switch err {
+			case nil:
+			case ErrInFlight:
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			case ErrMismatch:
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			default:
+				slog.Error("idempotency reserve failed", "scope", scope, "key", key, "error", err)
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+
+			if rec != nil {
+				replay(w, rec)
+				return
+			}
+
+			// 客户端断开时 r.Context() 会被取消, 保存结果和释放 key 不应随之失败
+			storeCtx := context.WithoutCancel(r.Context())
+			rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				// handler panic 或未保存结果时释放 key, 允许客户端重试
+				if !completed {
+					if err := Release(storeCtx, scope, key, token); err != nil {
+						slog.Error("idempotency release failed", "scope", scope, "key", key, "error", err)
+					}
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+
+			if rw.status >= 500 && !opts.CacheServerErrors {
+				return
+			}
+			err = Complete(storeCtx, scope, key, token, &Record{
+				Fingerprint: hex.EncodeToString(sum[:]),
+				StatusCode:  rw.status,
+				Header:      rw.Header().Clone(),
+				Body:        rw.body.Bytes(),
+			}, opts.TTL)
+			if err != nil {
+				slog.Error("idempotency complete failed", "scope", scope, "key", key, "error", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func replay(w http.ResponseWriter, rec *Record) {
+	for k, vv := range rec.Header {
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(rec.StatusCode)
+	w.Write(rec.Body)
+}
+
+// recorder 在写给客户端的同时记录响应
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,148 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/redis"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInFlight 表示相同 key 的请求正在处理中
+	ErrInFlight = errors.New("idempotency: request with the same key is in flight")
+	// ErrMismatch 表示相同 key 被用于内容不同的请求
+	ErrMismatch = errors.New("idempotency: key reused with a different request")
+	// ErrNotReserved 表示占用已过期或已被其他请求接管
+	ErrNotReserved = errors.New("idempotency: reservation expired or taken over")
+)
+
+const (
+	stateInFlight = "in_flight"
+	stateDone     = "done"
+)
+
+// Record 是保存在 redis 中的处理结果
+type Record struct {
+	State       string      `json:"state"`
+	Fingerprint string      `json:"fp,omitempty"`
+	Token       string      `json:"token,omitempty"` // 占用者 token, 只在处理中状态有值
+	StatusCode  int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+func storeKey(scope, key string) string {
+	return redis.Key("idempotency", scope, key)
+}
+
+// reserveScript 不存在时写入 in-flight 记录并返回 nil, 已存在时返回原记录
+var reserveScript = redis.RegisterScript("idempotency_reserve", `
+local v = redis.call('get', KEYS[1])
+if v then
+	return v
+end
+redis.call('set', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+
+// completeScript 仍由 ARGV[1] 占用时写入结果, 返回 1; 否则返回 0
+var completeScript = redis.RegisterScript("idempotency_complete", `
+local v = redis.call('get', KEYS[1])
+if not v then
+	return 0
+end
+local ok, rec = pcall(cjson.decode, v)
+if not ok or rec.state ~= 'in_flight' or rec.token ~= ARGV[1] then
+	return 0
+end
+redis.call('set', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// releaseScript 仍由 ARGV[1] 占用时删除 key, 返回 1; 否则返回 0
+var releaseScript = redis.RegisterScript("idempotency_release", `
+local v = redis.call('get', KEYS[1])
+if not v then
+	return 0
+end
+local ok, rec = pcall(cjson.decode, v)
+if not ok or rec.state ~= 'in_flight' or rec.token ~= ARGV[1] then
+	return 0
+end
+return redis.call('del', KEYS[1])
+`)
+
+// Reserve 原子地占用 key. 占用成功返回占用 token, 之后用于 Complete 或 Release;
+// 已有完成的结果时返回该记录; 处理中返回 ErrInFlight; fingerprint 不一致返回 ErrMismatch.
+// lockTTL 是处理中状态的最长保留时间, 超时后允许重新处理.
+func Reserve(ctx context.Context, scope, key, fingerprint string, lockTTL time.Duration) (*Record, string, error) {
+	token := uuid.NewString()
+	pending, err := json.Marshal(&Record{
+		State:       stateInFlight,
+		Fingerprint: fingerprint,
+		Token:       token,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	v, err := reserveScript.Run(ctx, []string{storeKey(scope, key)}, pending, lockTTL.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, token, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var rec Record
+	if err := json.Unmarshal([]byte(v), &rec); err != nil {
+		return nil, "", err
+	}
+	if rec.Fingerprint != fingerprint {
+		return nil, "", ErrMismatch
+	}
+	if rec.State != stateDone {
+		return nil, "", ErrInFlight
+	}
+	return &rec, "", nil
+}
+
+// Complete 保存处理结果, 之后 ttl 内的重放直接返回该结果.
+// 占用已过期或被其他请求接管时不写入, 返回 ErrNotReserved.
+func Complete(ctx context.Context, scope, key, token string, rec *Record, ttl time.Duration) error {
+	rec.State = stateDone
+	rec.Token = ""
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	n, err := completeScript.Run(ctx, []string{storeKey(scope, key)}, token, data, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotReserved
+	}
+	return nil
+}
+
+// Release 删除仍由 token 占用的 key, 用于处理失败后允许重试
+func Release(ctx context.Context, scope, key, token string) error {
+	n, err := releaseScript.Run(ctx, []string{storeKey(scope, key)}, token).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotReserved
+	}
+	return nil
+}
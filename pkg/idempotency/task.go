@@ -0,0 +1,89 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+type TaskOptions struct {
+	TTL     time.Duration // 成功结果保留时长, 默认 24 小时
+	LockTTL time.Duration // 处理中状态的最长保留时间, 默认 30 分钟, 应不短于任务超时
+
+	// KeyFunc 返回任务的幂等 key, 默认使用 asynq 任务id (去重同一任务的重复执行).
+	// 需要对重复入队的相同任务去重时可使用 PayloadKey.
+	KeyFunc func(ctx context.Context, task *asynq.Task) string
+}
+
+// PayloadKey 以任务类型和 payload 的哈希作为幂等 key
+func PayloadKey(ctx context.Context, task *asynq.Task) string {
+	sum := sha256.Sum256(task.Payload())
+	return hex.EncodeToString(sum[:])
+}
+
+func taskIDKey(ctx context.Context, task *asynq.Task) string {
+	id, _ := asynq.GetTaskID(ctx)
+	return id
+}
+
+// TaskHandler 包装任务处理器, 已成功处理过的 key 直接跳过:
+//
+//	tasklib.Consumer("order:charge", idempotency.TaskHandler(handler, nil))
+//
+// 处理失败时释放 key, 由 asynq 正常重试; 相同 key 处理中时返回错误, 稍后重试.
+func TaskHandler(handler asynq.Handler, opts *TaskOptions) asynq.Handler {
+	o := TaskOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.TTL <= 0 {
+		o.TTL = 24 * time.Hour
+	}
+	if o.LockTTL <= 0 {
+		o.LockTTL = 30 * time.Minute
+	}
+	if o.KeyFunc == nil {
+		o.KeyFunc = taskIDKey
+	}
+
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		scope := "task:" + task.Type()
+		key := o.KeyFunc(ctx, task)
+		if key == "" {
+			return handler.ProcessTask(ctx, task)
+		}
+
+		rec, token, err := Reserve(ctx, scope, key, "", o.LockTTL)
+		if err != nil {
+			return fmt.Errorf("idempotency: %w", err)
+		}
+		if rec != nil {
+			slog.Info("idempotency task already processed, skipping", "task", task.Type(), "key", key)
+			return nil
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				if err := Release(context.WithoutCancel(ctx), scope, key, token); err != nil {
+					slog.Error("idempotency release failed", "task", task.Type(), "key", key, "error", err)
+				}
+			}
+		}()
+
+		if err := handler.ProcessTask(ctx, task); err != nil {
+			return err
+		}
+		if err := Complete(context.WithoutCancel(ctx), scope, key, token, &Record{}, o.TTL); err != nil {
+			slog.Error("idempotency complete failed", "task", task.Type(), "key", key, "error", err)
+			return nil
+		}
+		completed = true
+		return nil
+	})
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"strings"
+
+	"github.com/flaboy/aira-core/pkg/config"
+)
+
+// KeyPrefix 返回配置的 REDIS_KEY_PREFIX, 不含结尾的冒号
+func KeyPrefix() string {
+	if config.Config == nil {
+		return ""
+	}
+	return strings.TrimRight(config.Config.RedisKeyPrefix, ":")
+}
+
+// Key 用冒号连接 parts 并加上 REDIS_KEY_PREFIX, 所有写入共享 redis 的 key 和频道都应通过它生成
+func Key(parts ...string) string {
+	key := strings.Join(parts, ":")
+	if prefix := KeyPrefix(); prefix != "" {
+		return prefix + ":" + key
+	}
+	return key
+}
+
+// StripKey 去掉 Key 加上的前缀
+func StripKey(key string) string {
+	if prefix := KeyPrefix(); prefix != "" {
+		return strings.TrimPrefix(key, prefix+":")
+	}
+	return key
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flaboy/aira-core/pkg/config"
+)
+
+func withKeyPrefix(t *testing.T, prefix string) {
+	t.Helper()
+	old := config.Config
+	config.Config = &config.InfraConfig{RedisKeyPrefix: prefix}
+	t.Cleanup(func() { config.Config = old })
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		prefix string
+		parts  []string
+		want   string
+	}{
+		{"", []string{"cache", "user", "1"}, "cache:user:1"},
+		{"app", []string{"cache", "user", "1"}, "app:cache:user:1"},
+		{"app:", []string{"cluster_master"}, "app:cluster_master"},
+		{"app::", []string{"a"}, "app:a"},
+		{"app", []string{"semaphore", "{jobs}"}, "app:semaphore:{jobs}"},
+	}
+	for _, tt := range tests {
+		withKeyPrefix(t, tt.prefix)
+		got := Key(tt.parts...)
+		if got != tt.want {
+			t.Errorf("prefix %q: Key(%q) = %q, want %q", tt.prefix, tt.parts, got, tt.want)
+		}
+		if want := strings.Join(tt.parts, ":"); StripKey(got) != want {
+			t.Errorf("prefix %q: StripKey(%q) = %q, want %q", tt.prefix, got, StripKey(got), want)
+		}
+	}
+}
+
+func TestStripKeyWithoutPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		key    string
+		want   string
+	}{
+		{"", "app:cache:1", "app:cache:1"},
+		{"app", "other:cache:1", "other:cache:1"},
+		{"app", "application:cache:1", "application:cache:1"},
+	}
+	for _, tt := range tests {
+		withKeyPrefix(t, tt.prefix)
+		if got := StripKey(tt.key); got != tt.want {
+			t.Errorf("prefix %q: StripKey(%q) = %q, want %q", tt.prefix, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestKeyWithoutConfig(t *testing.T) {
+	old := config.Config
+	config.Config = nil
+	t.Cleanup(func() { config.Config = old })
+	if got := Key("a", "b"); got != "a:b" {
+		t.Fatalf("Key without config = %q, want a:b", got)
+	}
+}
@@ -0,0 +1,31 @@
+if redis.replicate_commands then redis.replicate_commands() end
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+if count + cost > limit then
+	local retry = window
+	local idx = count + cost - limit
+	if idx <= count then
+		local entry = redis.call('ZRANGE', KEYS[1], idx - 1, idx - 1, 'WITHSCORES')
+		retry = tonumber(entry[2]) + window - now
+	end
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	local reset = window
+	if oldest[2] then
+		reset = tonumber(oldest[2]) + window - now
+	end
+	return {0, math.max(limit - count, 0), tostring(retry / 1000), tostring(reset / 1000)}
+end
+
+for i = 1, cost do
+	redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. i)
+end
+redis.call('PEXPIRE', KEYS[1], window)
+return {cost, limit - count - cost, '-1', tostring(window / 1000)}
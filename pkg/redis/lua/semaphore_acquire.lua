@@ -0,0 +1,49 @@
+if redis.replicate_commands then redis.replicate_commands() end
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local token = ARGV[1]
+local limit = tonumber(ARGV[2])
+local lease = tonumber(ARGV[3])
+local wait_lease = tonumber(ARGV[4])
+
+redis.call('zremrangebyscore', KEYS[1], '-inf', now)
+local stale = redis.call('zrangebyscore', KEYS[3], '-inf', now)
+for _, member in ipairs(stale) do
+	redis.call('zrem', KEYS[2], member)
+end
+redis.call('zremrangebyscore', KEYS[3], '-inf', now)
+
+-- 只延长不缩短各 key 的过期时间, 以免影响使用更长租约的持有者
+local ttl = lease + wait_lease
+local function touch()
+	for i = 1, 4 do
+		if redis.call('pttl', KEYS[i]) < ttl then
+			redis.call('pexpire', KEYS[i], ttl)
+		end
+	end
+end
+
+if redis.call('zscore', KEYS[1], token) then
+	return 1
+end
+if not redis.call('zscore', KEYS[2], token) then
+	redis.call('zadd', KEYS[2], redis.call('incr', KEYS[4]), token)
+end
+
+local rank = redis.call('zrank', KEYS[2], token)
+local free = limit - redis.call('zcard', KEYS[1])
+if rank < free then
+	redis.call('zrem', KEYS[2], token)
+	redis.call('zrem', KEYS[3], token)
+	redis.call('zadd', KEYS[1], now + lease, token)
+	touch()
+	return 1
+end
+
+if ARGV[5] == '0' then
+	redis.call('zrem', KEYS[2], token)
+	return 0
+end
+redis.call('zadd', KEYS[3], now + wait_lease, token)
+touch()
+return 0
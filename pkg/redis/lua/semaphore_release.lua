@@ -0,0 +1,3 @@
+redis.call('zrem', KEYS[2], ARGV[1])
+redis.call('zrem', KEYS[3], ARGV[1])
+return redis.call('zrem', KEYS[1], ARGV[1])
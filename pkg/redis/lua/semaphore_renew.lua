@@ -0,0 +1,12 @@
+if redis.replicate_commands then redis.replicate_commands() end
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local score = redis.call('zscore', KEYS[1], ARGV[1])
+if not score or tonumber(score) < now then
+	return 0
+end
+redis.call('zadd', KEYS[1], 'XX', now + tonumber(ARGV[2]), ARGV[1])
+if redis.call('pttl', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('pexpire', KEYS[1], ARGV[2])
+end
+return 1
@@ -0,0 +1,184 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLockNotHeld     = errors.New("redis: lock not held")
+	ErrLockAlreadyHeld = errors.New("redis: lock already held by this mutex")
+)
+
+const (
+	lockMinBackoff = 20 * time.Millisecond
+	lockMaxBackoff = time.Second
+)
+
+// KEYS[1] 锁, KEYS[2] fencing 计数器; ARGV[1] 持有者 token, ARGV[2] 租约毫秒数
+var lockScript = builtinScripts["mutex_lock"]
+
+var unlockScript = builtinScripts["mutex_unlock"]
+
+var extendScript = builtinScripts["mutex_extend"]
+
+type mutex struct {
+	key      string
+	fenceKey string
+
+	mu    sync.Mutex
+	token string
+	fence int64
+	stop  chan struct{}
+	lost  chan struct{}
+}
+
+// Mutex 返回基于 redis 的分布式锁. 每次加锁使用随机 token, 只有持有者可以解锁;
+// 持有期间后台会自动续约, 直到 Unlock 或续约失败.
+func Mutex(key string) *mutex {
+	key = Key(key)
+	return &mutex{
+		key:      key,
+		fenceKey: fenceKey(key),
+	}
+}
+
+// fenceKey 与锁 key 落在同一个 cluster slot, 以便在同一个脚本中访问
+func fenceKey(key string) string {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			return key + ":fence"
+		}
+	}
+	return "{" + key + "}:fence"
+}
+
+// Lock 阻塞直到获得锁, 兼容旧接口
+func (m *mutex) Lock(ttl time.Duration) error {
+	_, err := m.LockContext(context.Background(), ttl)
+	return err
+}
+
+// LockContext 阻塞直到获得锁或 ctx 结束, 返回单调递增的 fencing token
+func (m *mutex) LockContext(ctx context.Context, ttl time.Duration) (int64, error) {
+	backoff := lockMinBackoff
+	for {
+		fence, ok, err := m.TryLock(ctx, ttl)
+		if err != nil || ok {
+			return fence, err
+		}
+
+		// 带抖动的指数退避, 避免多个等待者同时重试
+		wait := backoff/2 + rand.N(backoff)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, lockMaxBackoff)
+	}
+}
+
+// TryLock 尝试获取一次锁, 不等待. ttl 不足 1 毫秒时返回 ErrInvalidLease
+func (m *mutex) TryLock(ctx context.Context, ttl time.Duration) (int64, bool, error) {
+	if ttl < time.Millisecond {
+		return 0, false, ErrInvalidLease
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token != "" {
+		return 0, false, ErrLockAlreadyHeld
+	}
+
+	token := uuid.NewString()
+	fence, err := lockScript.Run(ctx, []string{m.key, m.fenceKey}, token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	if fence == 0 {
+		return 0, false, nil
+	}
+
+	m.token = token
+	m.fence = fence
+	m.stop = make(chan struct{})
+	m.lost = make(chan struct{})
+	go m.watchdog(token, ttl, m.stop, m.lost)
+	return fence, true, nil
+}
+
+// watchdog 每 ttl/3 续约一次; 锁被他人占有或超过 ttl 未能续约时关闭 lost
+func (m *mutex) watchdog(token string, ttl time.Duration, stop, lost chan struct{}) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	lastExtended := time.Now()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), ttl/3)
+		n, err := extendScript.Run(ctx, []string{m.key}, token, ttl.Milliseconds()).Int64()
+		cancel()
+		if err == nil && n == 1 {
+			lastExtended = time.Now()
+			continue
+		}
+		if err == nil || time.Since(lastExtended) >= ttl {
+			close(lost)
+			return
+		}
+	}
+}
+
+// Unlock 释放锁, 兼容旧接口
+func (m *mutex) Unlock() error {
+	return m.UnlockContext(context.Background())
+}
+
+// UnlockContext 仅当锁仍由本实例持有时才删除, 否则返回 ErrLockNotHeld
+func (m *mutex) UnlockContext(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token == "" {
+		return ErrLockNotHeld
+	}
+
+	token := m.token
+	close(m.stop)
+	m.token = ""
+	m.fence = 0
+	m.stop = nil
+
+	n, err := unlockScript.Run(ctx, []string{m.key}, token).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Fence 返回当前持有锁的 fencing token, 未持有时为 0
+func (m *mutex) Fence() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fence
+}
+
+// Lost 返回的 channel 在续约失败、锁已不再属于本实例时关闭
+func (m *mutex) Lost() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lost
+}
@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFenceKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"lock:orders", "{lock:orders}:fence"},
+		{"app:lock:{orders}", "app:lock:{orders}:fence"},
+		{"lock:{}:x", "{lock:{}:x}:fence"}, // 空 hash tag 不生效
+		{"lock:{orders", "{lock:{orders}:fence"},
+	}
+	for _, tt := range tests {
+		if got := fenceKey(tt.key); got != tt.want {
+			t.Errorf("fenceKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMutexKey(t *testing.T) {
+	withKeyPrefix(t, "app")
+	m := Mutex("jobs")
+	if m.key != "app:jobs" || m.fenceKey != "{app:jobs}:fence" {
+		t.Fatalf("Mutex keys = %q, %q", m.key, m.fenceKey)
+	}
+}
+
+func TestMutexInvalidTTL(t *testing.T) {
+	m := Mutex("invalid-ttl")
+	for _, ttl := range []time.Duration{0, -time.Second, time.Microsecond} {
+		if _, ok, err := m.TryLock(context.Background(), ttl); ok || err != ErrInvalidLease {
+			t.Errorf("TryLock(%v) = %v, %v; want ErrInvalidLease", ttl, ok, err)
+		}
+	}
+	if _, err := m.LockContext(context.Background(), 0); err != ErrInvalidLease {
+		t.Errorf("LockContext(0) error = %v, want ErrInvalidLease", err)
+	}
+	if err := m.UnlockContext(context.Background()); err != ErrLockNotHeld {
+		t.Errorf("UnlockContext without lock = %v, want ErrLockNotHeld", err)
+	}
+}
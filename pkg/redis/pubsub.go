@@ -0,0 +1,258 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+type Message = redis.Message
+
+const (
+	subscribeReceiveTimeout = 30 * time.Second
+	subscribeMaxBackoff     = 5 * time.Second
+)
+
+// Publish sends a message to a Redis channel.
+func Publish(channel string, message interface{}) error {
+	return PublishContext(context.Background(), channel, message)
+}
+
+// PublishContext sends a message to a Redis channel.
+// Channel names are namespaced with REDIS_KEY_PREFIX.
+func PublishContext(ctx context.Context, channel string, message interface{}) error {
+	return RedisClient.Publish(ctx, Key(channel), message).Err()
+}
+
+// PublishJSON encodes v as JSON and publishes it to a Redis channel.
+func PublishJSON[T any](ctx context.Context, channel string, v T) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return PublishContext(ctx, channel, payload)
+}
+
+// Subscribe listens for messages on a Redis channel and returns a channel for receiving messages.
+//
+// Deprecated: the subscription can never be closed; use SubscribeContext instead.
+func Subscribe(channel string) (<-chan *redis.Message, error) {
+	sub, err := SubscribeContext(context.Background(), channel)
+	if err != nil {
+		return nil, err
+	}
+	return sub.Messages(), nil
+}
+
+// SubscriptionState reports the connection state of a Subscription.
+// Connected is false with Err set when the connection is lost, and
+// true again once the channels have been re-subscribed.
+type SubscriptionState struct {
+	Connected bool
+	Err       error
+}
+
+// Subscription is a closable subscription to one or more channels or patterns.
+type Subscription struct {
+	ps     *redis.PubSub
+	msgs   chan *redis.Message
+	states chan SubscriptionState
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// SubscribeContext subscribes to channels. The subscription is confirmed
+// before returning and lives until Close is called or ctx is done.
+func SubscribeContext(ctx context.Context, channels ...string) (*Subscription, error) {
+	return newSubscription(ctx, RedisClient.Subscribe(ctx, namespaced(channels)...))
+}
+
+// PSubscribe subscribes to channels matching the given patterns.
+func PSubscribe(ctx context.Context, patterns ...string) (*Subscription, error) {
+	return newSubscription(ctx, RedisClient.PSubscribe(ctx, namespaced(patterns)...))
+}
+
+func namespaced(names []string) []string {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = Key(name)
+	}
+	return keys
+}
+
+func newSubscription(ctx context.Context, ps *redis.PubSub) (*Subscription, error) {
+	// 等待订阅确认, 保证返回后发布的消息不会丢失
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		ps:     ps,
+		msgs:   make(chan *redis.Message, 100),
+		states: make(chan SubscriptionState, 1),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go s.run(ctx)
+	return s, nil
+}
+
+// Messages returns the channel on which messages are delivered.
+// It is closed when the subscription ends.
+func (s *Subscription) Messages() <-chan *redis.Message {
+	return s.msgs
+}
+
+// States delivers connection state changes. Slow readers only see the latest state.
+func (s *Subscription) States() <-chan SubscriptionState {
+	return s.states
+}
+
+// Done is closed once the subscription has ended.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close ends the subscription and releases its connection.
+func (s *Subscription) Close() error {
+	s.once.Do(func() {
+		s.cancel()
+		<-s.done
+	})
+	return nil
+}
+
+func (s *Subscription) setState(state SubscriptionState) {
+	select {
+	case <-s.states:
+	default:
+	}
+	s.states <- state
+}
+
+func (s *Subscription) run(ctx context.Context) {
+	defer close(s.done)
+	defer close(s.msgs)
+
+	// 关闭连接以中断阻塞中的 Receive
+	go func() {
+		<-ctx.Done()
+		s.ps.Close()
+	}()
+	defer s.cancel()
+
+	connected := true
+	backoff := 100 * time.Millisecond
+	for {
+		msg, err := s.ps.ReceiveTimeout(ctx, subscribeReceiveTimeout)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, redis.ErrClosed) {
+				return
+			}
+			// 空闲超时时发送 ping 检查连接, ping 失败会触发重连
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				if err = s.ps.Ping(ctx); err == nil {
+					continue
+				}
+			}
+			if connected {
+				connected = false
+				s.setState(SubscriptionState{Connected: false, Err: err})
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, subscribeMaxBackoff)
+			continue
+		}
+
+		if !connected {
+			connected = true
+			backoff = 100 * time.Millisecond
+			s.setState(SubscriptionState{Connected: true})
+		}
+
+		if m, ok := msg.(*redis.Message); ok {
+			if KeyPrefix() != "" {
+				stripped := *m
+				stripped.Channel = StripKey(m.Channel)
+				stripped.Pattern = StripKey(m.Pattern)
+				m = &stripped
+			}
+			select {
+			case s.msgs <- m:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// JSONMessage is a message decoded by SubscribeJSON. Err is set when the
+// payload could not be decoded into T.
+type JSONMessage[T any] struct {
+	Channel string
+	Pattern string
+	Data    T
+	Err     error
+}
+
+// JSONSubscription is a Subscription delivering decoded JSON payloads.
+type JSONSubscription[T any] struct {
+	*Subscription
+	msgs chan JSONMessage[T]
+}
+
+// Messages returns the channel on which decoded messages are delivered.
+func (s *JSONSubscription[T]) Messages() <-chan JSONMessage[T] {
+	return s.msgs
+}
+
+// SubscribeJSON subscribes to channels and decodes each payload as JSON into T.
+func SubscribeJSON[T any](ctx context.Context, channels ...string) (*JSONSubscription[T], error) {
+	sub, err := SubscribeContext(ctx, channels...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON[T](sub), nil
+}
+
+// PSubscribeJSON subscribes to patterns and decodes each payload as JSON into T.
+func PSubscribeJSON[T any](ctx context.Context, patterns ...string) (*JSONSubscription[T], error) {
+	sub, err := PSubscribe(ctx, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON[T](sub), nil
+}
+
+func decodeJSON[T any](sub *Subscription) *JSONSubscription[T] {
+	s := &JSONSubscription[T]{
+		Subscription: sub,
+		msgs:         make(chan JSONMessage[T], 100),
+	}
+	go func() {
+		defer close(s.msgs)
+		for m := range sub.Messages() {
+			msg := JSONMessage[T]{Channel: m.Channel, Pattern: m.Pattern}
+			msg.Err = json.Unmarshal([]byte(m.Payload), &msg.Data)
+			select {
+			case s.msgs <- msg:
+			case <-sub.Done():
+				return
+			}
+		}
+	}()
+	return s
+}
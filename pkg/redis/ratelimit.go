@@ -0,0 +1,234 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	redis "github.com/redis/go-redis/v9"
+)
+
+// gcraScript 实现 GCRA, KEYS[1] 保存理论到达时间 (TAT)
+// ARGV: burst, rate, period(秒), cost
+var gcraScript = builtinScripts["ratelimit_gcra"]
+
+// slidingWindowScript 实现滑动窗口日志, KEYS[1] 是以请求时间为 score 的有序集合
+// ARGV: limit, window(毫秒), cost, 成员前缀
+var slidingWindowScript = builtinScripts["ratelimit_sliding_window"]
+
+type RateLimitAlgorithm int
+
+const (
+	// GCRA 平滑限流, 允许 Burst 大小的突发
+	GCRA RateLimitAlgorithm = iota
+	// SlidingWindow 精确限制任意 Period 窗口内最多 Rate 次, 每次请求占用一个有序集合成员
+	SlidingWindow
+)
+
+// RateLimit 表示每 Period 允许 Rate 次, Burst 仅用于 GCRA, 默认等于 Rate
+type RateLimit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+func PerSecond(rate int) RateLimit {
+	return RateLimit{Rate: rate, Period: time.Second, Burst: rate}
+}
+
+func PerMinute(rate int) RateLimit {
+	return RateLimit{Rate: rate, Period: time.Minute, Burst: rate}
+}
+
+func PerHour(rate int) RateLimit {
+	return RateLimit{Rate: rate, Period: time.Hour, Burst: rate}
+}
+
+// ErrRateLimitExceeded 表示一次请求的数量超过了限流器的容量, 永远不会被放行
+var ErrRateLimitExceeded = errors.New("redis: rate limit request exceeds capacity")
+
+// ErrInvalidRateLimit 表示 Rate 不是正数或 Period 不足 1 毫秒
+var ErrInvalidRateLimit = errors.New("redis: rate limit requires a positive rate and a period of at least 1ms")
+
+// RateLimitResult 是一次限流判断的结果
+type RateLimitResult struct {
+	Allowed    int           // 本次放行的数量, 0 表示被限流
+	Remaining  int           // 当前还可放行的数量
+	RetryAfter time.Duration // 被限流时需等待的时长, 放行时为 -1
+	ResetAfter time.Duration // 限流状态完全恢复所需时长
+}
+
+// RateLimiter 是基于 redis 的分布式限流器, 所有节点共享同一计数
+type RateLimiter struct {
+	name  string
+	limit RateLimit
+	algo  RateLimitAlgorithm
+}
+
+func (r RateLimit) valid() bool {
+	return r.Rate > 0 && r.Period >= time.Millisecond
+}
+
+// NewRateLimiter 创建限流器, name 用于区分不同用途的限流 key.
+// Rate 不是正数或 Period 不足 1 毫秒时, 之后的调用返回 ErrInvalidRateLimit.
+func NewRateLimiter(name string, limit RateLimit, algo RateLimitAlgorithm) *RateLimiter {
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Rate
+	}
+	return &RateLimiter{
+		name:  name,
+		limit: limit,
+		algo:  algo,
+	}
+}
+
+func (l *RateLimiter) key(key string) string {
+	return Key("ratelimit", l.name, key)
+}
+
+// Allow 判断 key 是否可以放行一次
+func (l *RateLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN 判断 key 是否可以放行 n 次, 放行时计入用量
+func (l *RateLimiter) AllowN(ctx context.Context, key string, n int) (*RateLimitResult, error) {
+	if !l.limit.valid() {
+		return nil, ErrInvalidRateLimit
+	}
+	var cmd *redis.Cmd
+	switch l.algo {
+	case SlidingWindow:
+		cmd = slidingWindowScript.Run(ctx, []string{l.key(key)},
+			l.limit.Rate, l.limit.Period.Milliseconds(), n, uuid.NewString())
+	default:
+		cmd = gcraScript.Run(ctx, []string{l.key(key)},
+			l.limit.Burst, l.limit.Rate, l.limit.Period.Seconds(), n)
+	}
+	values, err := cmd.Slice()
+	if err != nil {
+		return nil, err
+	}
+	return parseRateLimitResult(values)
+}
+
+func parseRateLimitResult(values []interface{}) (*RateLimitResult, error) {
+	if len(values) != 4 {
+		return nil, errors.New("redis: unexpected rate limit script result")
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(int64)
+	retryAfter, err := strconv.ParseFloat(values[2].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+	resetAfter, err := strconv.ParseFloat(values[3].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RateLimitResult{
+		Allowed:    int(allowed),
+		Remaining:  int(remaining),
+		RetryAfter: -1,
+		ResetAfter: secondsToDuration(resetAfter),
+	}
+	if retryAfter >= 0 {
+		res.RetryAfter = secondsToDuration(retryAfter)
+	}
+	return res, nil
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Wait 阻塞直到 key 可以放行一次或 ctx 结束, 适用于后台任务
+func (l *RateLimiter) Wait(ctx context.Context, key string) error {
+	return l.WaitN(ctx, key, 1)
+}
+
+// WaitN 阻塞直到 key 可以放行 n 次或 ctx 结束. n 超过容量 (GCRA 为 Burst, SlidingWindow 为 Rate)
+// 时立即返回 ErrRateLimitExceeded.
+func (l *RateLimiter) WaitN(ctx context.Context, key string, n int) error {
+	if !l.limit.valid() {
+		return ErrInvalidRateLimit
+	}
+	if n > l.capacity() {
+		return ErrRateLimitExceeded
+	}
+	for {
+		res, err := l.AllowN(ctx, key, n)
+		if err != nil {
+			return err
+		}
+		if res.Allowed > 0 {
+			return nil
+		}
+
+		wait := res.RetryAfter
+		if wait <= 0 {
+			wait = 10 * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// capacity 是一次最多可以放行的数量
+func (l *RateLimiter) capacity() int {
+	if l.algo == SlidingWindow {
+		return l.limit.Rate
+	}
+	return l.limit.Burst
+}
+
+// Reset 清除 key 的限流状态
+func (l *RateLimiter) Reset(ctx context.Context, key string) error {
+	return RedisClient.Del(ctx, l.key(key)).Err()
+}
+
+// RateLimitMiddleware 返回按 keyFunc 限流的 http 中间件, keyFunc 为 nil 时按客户端 IP 限流.
+// 被限流时返回 429 并设置 Retry-After; redis 出错时放行请求.
+func RateLimitMiddleware(l *RateLimiter, keyFunc func(r *http.Request) string) func(http.Handler) http.Handler {
+	if keyFunc == nil {
+		keyFunc = clientIP
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := l.Allow(r.Context(), keyFunc(r))
+			if err != nil {
+				slog.Error("rate limit check failed", "limiter", l.name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.capacity()))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			if res.Allowed == 0 {
+				retry := int(math.Ceil(res.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(retry, 1)))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimitCapacity(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit RateLimit
+		algo  RateLimitAlgorithm
+		want  int
+	}{
+		{"gcra burst defaults to rate", RateLimit{Rate: 10, Period: time.Second}, GCRA, 10},
+		{"gcra burst", RateLimit{Rate: 10, Period: time.Second, Burst: 50}, GCRA, 50},
+		{"sliding window ignores burst", RateLimit{Rate: 10, Period: time.Second, Burst: 50}, SlidingWindow, 10},
+		{"per minute", PerMinute(100), GCRA, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter("test", tt.limit, tt.algo)
+			if got := l.capacity(); got != tt.want {
+				t.Fatalf("capacity() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit RateLimit
+	}{
+		{"zero rate", RateLimit{Period: time.Second}},
+		{"negative rate", RateLimit{Rate: -1, Period: time.Second}},
+		{"zero period", RateLimit{Rate: 10}},
+		{"sub-millisecond period", RateLimit{Rate: 10, Period: time.Microsecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, algo := range []RateLimitAlgorithm{GCRA, SlidingWindow} {
+				l := NewRateLimiter("test", tt.limit, algo)
+				if _, err := l.Allow(context.Background(), "k"); err != ErrInvalidRateLimit {
+					t.Errorf("Allow error = %v, want ErrInvalidRateLimit", err)
+				}
+				if err := l.Wait(context.Background(), "k"); err != ErrInvalidRateLimit {
+					t.Errorf("Wait error = %v, want ErrInvalidRateLimit", err)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitNExceedsCapacity(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit RateLimit
+		algo  RateLimitAlgorithm
+		n     int
+	}{
+		{"gcra above burst", RateLimit{Rate: 10, Period: time.Second, Burst: 20}, GCRA, 21},
+		{"sliding window above rate", RateLimit{Rate: 10, Period: time.Second, Burst: 20}, SlidingWindow, 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter("test", tt.limit, tt.algo)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := l.WaitN(ctx, "k", tt.n); err != ErrRateLimitExceeded {
+				t.Fatalf("WaitN(%d) error = %v, want ErrRateLimitExceeded", tt.n, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,12 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/flaboy/aira-core/pkg/config"
@@ -10,65 +16,81 @@ import (
 )
 
 var (
-	RedisClient *redis.Client
+	// RedisClient 根据配置可能是单机、sentinel 或 cluster 客户端
+	RedisClient redis.UniversalClient
 )
 
 var Nil = redis.Nil
 
 func InitRedis() error {
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     config.Config.RedisAddr,
-		Password: config.Config.RedisPassword,
-		DB:       config.Config.RedisDB,
-	})
+	opts, err := universalOptions(config.Config)
+	if err != nil {
+		return err
+	}
+	RedisClient = redis.NewUniversalClient(opts)
 
 	ctx, cFun := context.WithTimeout(context.Background(), time.Second)
 	defer cFun()
 
-	_, err := RedisClient.Ping(ctx).Result()
+	_, err = RedisClient.Ping(ctx).Result()
 	if err != nil {
 		return err
 	}
-	return nil
-}
 
-type mutex struct {
-	ctx context.Context
-	key string
+	// 预加载失败不影响使用, 执行时遇到 NOSCRIPT 会回退到 EVAL
+	loadCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := LoadScripts(loadCtx); err != nil {
+		slog.Warn("redis: preload scripts failed", "error", err)
+	}
+	return nil
 }
 
-func (m *mutex) Lock(ttl time.Duration) error {
-	for {
-		cmd := RedisClient.SetNX(m.ctx, m.key, 1, ttl)
-		if cmd.Err() != nil {
-			return cmd.Err()
+func universalOptions(cfg *config.InfraConfig) (*redis.UniversalOptions, error) {
+	var addrs []string
+	for _, addr := range strings.Split(cfg.RedisAddr, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
 		}
-		if cmd.Val() {
-			return nil
-		}
-		time.Sleep(100 * time.Millisecond)
 	}
-}
-
-func (m *mutex) Unlock() error {
-	cmd := RedisClient.Del(m.ctx, m.key)
-	return cmd.Err()
-}
-
-func Mutex(key string) *mutex {
-	return &mutex{
-		ctx: context.Background(),
-		key: key,
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("redis: REDIS_ADDR is empty")
 	}
-}
 
-// Publish sends a message to a Redis channel.
-func Publish(channel, message string) error {
-	return RedisClient.Publish(context.Background(), channel, message).Err()
-}
+	opts := &redis.UniversalOptions{
+		Addrs:            addrs,
+		Username:         cfg.RedisUsername,
+		Password:         cfg.RedisPassword,
+		DB:               cfg.RedisDB,
+		MasterName:       cfg.RedisMasterName,
+		SentinelUsername: cfg.RedisSentinelUsername,
+		SentinelPassword: cfg.RedisSentinelPassword,
+		IsClusterMode:    cfg.RedisClusterMode,
+		PoolSize:         cfg.RedisPoolSize,
+		DialTimeout:      time.Duration(cfg.RedisDialTimeoutMs) * time.Millisecond,
+		ReadTimeout:      time.Duration(cfg.RedisReadTimeoutMs) * time.Millisecond,
+		WriteTimeout:     time.Duration(cfg.RedisWriteTimeoutMs) * time.Millisecond,
+	}
 
-// Subscribe listens for messages on a Redis channel and returns a channel for receiving messages.
-func Subscribe(channel string) (<-chan *redis.Message, error) {
-	sub := RedisClient.Subscribe(context.Background(), channel)
-	return sub.Channel(), nil
+	if cfg.RedisTLS {
+		// ServerName 留空时 go-redis 按实际连接的地址校验证书, 以支持 sentinel 解析出的主节点和 cluster 的各个节点
+		tlsConfig := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.RedisTLSSkipVerify,
+			ServerName:         cfg.RedisTLSServerName,
+		}
+		if cfg.RedisTLSCAFile != "" {
+			pem, err := os.ReadFile(cfg.RedisTLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("redis: read TLS CA file failed: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("redis: no certificates found in %s", cfg.RedisTLSCAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		opts.TLSConfig = tlsConfig
+	}
+	return opts, nil
 }
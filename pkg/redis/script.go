@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+//go:embed lua/*.lua
+var luaFS embed.FS
+
+// 本包内置的脚本
+var builtinScripts = MustRegisterScriptsFS(luaFS, "lua/*.lua")
+
+var (
+	scripts   = make(map[string]*Script)
+	scriptsMu sync.Mutex
+)
+
+// Script 是注册到脚本表中的 Lua 脚本, 通过 EVALSHA 执行, 遇到 NOSCRIPT 时自动回退到 EVAL
+type Script struct {
+	name   string
+	script *redis.Script
+}
+
+// RegisterScript 注册脚本, InitRedis 时会预先加载到所有 master 节点. 同名脚本不能重复注册.
+func RegisterScript(name, src string) *Script {
+	scriptsMu.Lock()
+	defer scriptsMu.Unlock()
+	if _, exists := scripts[name]; exists {
+		panic("redis: script already registered: " + name)
+	}
+	s := &Script{
+		name:   name,
+		script: redis.NewScript(src),
+	}
+	scripts[name] = s
+	return s
+}
+
+// RegisterScriptsFS 注册 fsys 中匹配 pattern 的 .lua 文件, 以去掉扩展名的文件名作为脚本名:
+//
+//	//go:embed lua/*.lua
+//	var luaFS embed.FS
+//	var scripts = redis.MustRegisterScriptsFS(luaFS, "lua/*.lua")
+func RegisterScriptsFS(fsys fs.FS, pattern string) (map[string]*Script, error) {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*Script, len(files))
+	for _, file := range files {
+		src, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+		result[name] = RegisterScript(name, string(src))
+	}
+	return result, nil
+}
+
+// MustRegisterScriptsFS 与 RegisterScriptsFS 相同, 出错时 panic, 适合在包初始化时使用
+func MustRegisterScriptsFS(fsys fs.FS, pattern string) map[string]*Script {
+	result, err := RegisterScriptsFS(fsys, pattern)
+	if err != nil {
+		panic(fmt.Sprintf("redis: register scripts %s failed: %v", pattern, err))
+	}
+	return result
+}
+
+// GetScript 按名称返回已注册的脚本, 不存在时返回 nil
+func GetScript(name string) *Script {
+	scriptsMu.Lock()
+	defer scriptsMu.Unlock()
+	return scripts[name]
+}
+
+func (s *Script) Name() string {
+	return s.name
+}
+
+func (s *Script) Hash() string {
+	return s.script.Hash()
+}
+
+// Run 通过 EVALSHA 执行脚本. cluster 模式下按第一个 key 路由, 所有 key 必须在同一个 slot.
+func (s *Script) Run(ctx context.Context, keys []string, args ...interface{}) *redis.Cmd {
+	return s.script.Run(ctx, RedisClient, keys, args...)
+}
+
+// LoadScripts 将所有已注册的脚本加载到每个 master 节点
+func LoadScripts(ctx context.Context) error {
+	scriptsMu.Lock()
+	list := make([]*Script, 0, len(scripts))
+	for _, s := range scripts {
+		list = append(list, s)
+	}
+	scriptsMu.Unlock()
+
+	load := func(ctx context.Context, c redis.Scripter) error {
+		for _, s := range list {
+			if err := s.script.Load(ctx, c).Err(); err != nil {
+				return fmt.Errorf("load script %s: %w", s.name, err)
+			}
+		}
+		return nil
+	}
+
+	if cc, ok := RedisClient.(*redis.ClusterClient); ok {
+		return cc.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return load(ctx, c)
+		})
+	}
+	return load(ctx, RedisClient)
+}
@@ -0,0 +1,179 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSemaphoreLeaseLost = errors.New("redis: semaphore lease lost")
+	// ErrInvalidLease 表示信号量租约或锁的 ttl 不足 1 毫秒
+	ErrInvalidLease = errors.New("redis: lease must be at least 1ms")
+)
+
+// 信号量使用四个 key, 通过 hash tag 落在同一个 cluster slot:
+// holders 有序集合 (score 为租约到期时间), queue 有序集合 (score 为排队序号),
+// waiters 有序集合 (score 为等待者心跳到期时间), ticket 排队序号计数器.
+
+// KEYS: holders, queue, waiters, ticket
+// ARGV: token, limit, 租约毫秒数, 等待心跳毫秒数, 是否排队等待(1/0)
+var semaphoreAcquireScript = builtinScripts["semaphore_acquire"]
+
+// KEYS: holders; ARGV: token, 租约毫秒数
+var semaphoreRenewScript = builtinScripts["semaphore_renew"]
+
+// KEYS: holders, queue, waiters; ARGV: token
+var semaphoreReleaseScript = builtinScripts["semaphore_release"]
+
+const semaphoreWaitLease = 10 * time.Second
+
+type semaphore struct {
+	name  string
+	limit int
+	keys  []string
+}
+
+// Semaphore 返回集群范围内最多允许 limit 个持有者的计数信号量.
+// 等待者按排队顺序获得许可, 持有者的租约在持有期间自动续约.
+func Semaphore(name string, limit int) *semaphore {
+	base := Key("semaphore", "{"+name+"}")
+	return &semaphore{
+		name:  name,
+		limit: limit,
+		keys:  []string{base + ":holders", base + ":queue", base + ":waiters", base + ":ticket"},
+	}
+}
+
+// Acquire 阻塞直到获得许可或 ctx 结束, 放弃等待时会退出队列
+func (s *semaphore) Acquire(ctx context.Context, lease time.Duration) (*SemaphoreLease, error) {
+	if lease < time.Millisecond {
+		return nil, ErrInvalidLease
+	}
+	token := uuid.NewString()
+	backoff := lockMinBackoff
+	for {
+		ok, err := s.tryAcquire(ctx, token, lease, true)
+		if err != nil {
+			s.release(context.WithoutCancel(ctx), token)
+			return nil, err
+		}
+		if ok {
+			return s.newLease(token, lease), nil
+		}
+
+		wait := backoff/2 + rand.N(backoff)
+		select {
+		case <-ctx.Done():
+			s.release(context.WithoutCancel(ctx), token)
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, lockMaxBackoff)
+	}
+}
+
+// TryAcquire 尝试获取一次许可, 不排队等待; 有其他等待者时不会插队
+func (s *semaphore) TryAcquire(ctx context.Context, lease time.Duration) (*SemaphoreLease, bool, error) {
+	if lease < time.Millisecond {
+		return nil, false, ErrInvalidLease
+	}
+	token := uuid.NewString()
+	ok, err := s.tryAcquire(ctx, token, lease, false)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return s.newLease(token, lease), true, nil
+}
+
+// Holders 返回当前持有者数量
+func (s *semaphore) Holders(ctx context.Context) (int64, error) {
+	now := time.Now().UnixMilli()
+	return RedisClient.ZCount(ctx, s.keys[0], "("+strconv.FormatInt(now, 10), "+inf").Result()
+}
+
+func (s *semaphore) tryAcquire(ctx context.Context, token string, lease time.Duration, wait bool) (bool, error) {
+	enqueue := "0"
+	if wait {
+		enqueue = "1"
+	}
+	n, err := semaphoreAcquireScript.Run(ctx, s.keys,
+		token, s.limit, lease.Milliseconds(), semaphoreWaitLease.Milliseconds(), enqueue).Int64()
+	return n == 1, err
+}
+
+func (s *semaphore) release(ctx context.Context, token string) (bool, error) {
+	n, err := semaphoreReleaseScript.Run(ctx, s.keys[:3], token).Int64()
+	return n == 1, err
+}
+
+func (s *semaphore) newLease(token string, lease time.Duration) *SemaphoreLease {
+	l := &SemaphoreLease{
+		sem:   s,
+		token: token,
+		stop:  make(chan struct{}),
+		lost:  make(chan struct{}),
+	}
+	go l.renew(lease)
+	return l
+}
+
+// SemaphoreLease 是一次获得的许可
+type SemaphoreLease struct {
+	sem   *semaphore
+	token string
+	once  sync.Once
+	stop  chan struct{}
+	lost  chan struct{}
+}
+
+// renew 每 lease/3 续约一次, 许可已过期或超过 lease 未能续约时关闭 lost
+func (l *SemaphoreLease) renew(lease time.Duration) {
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+	lastRenewed := time.Now()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), lease/3)
+		n, err := semaphoreRenewScript.Run(ctx, l.sem.keys[:1], l.token, lease.Milliseconds()).Int64()
+		cancel()
+		if err == nil && n == 1 {
+			lastRenewed = time.Now()
+			continue
+		}
+		if err == nil || time.Since(lastRenewed) >= lease {
+			close(l.lost)
+			return
+		}
+	}
+}
+
+// Lost 返回的 channel 在许可已不再有效时关闭
+func (l *SemaphoreLease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release 归还许可; 许可已过期时返回 ErrSemaphoreLeaseLost
+func (l *SemaphoreLease) Release(ctx context.Context) error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		var ok bool
+		ok, err = l.sem.release(ctx, l.token)
+		if err == nil && !ok {
+			err = ErrSemaphoreLeaseLost
+		}
+	})
+	return err
+}
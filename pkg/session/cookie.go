@@ -0,0 +1,62 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/flaboy/aira-core/pkg/config"
+)
+
+// ErrNoSecret 表示未配置 APP_SECRET, 无法签发会话 cookie
+var ErrNoSecret = errors.New("session: APP_SECRET is not configured")
+
+// newID 生成 256 位随机会话id
+func newID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func secret() ([]byte, error) {
+	if config.Config == nil || config.Config.AppSecret == "" {
+		return nil, ErrNoSecret
+	}
+	return []byte(config.Config.AppSecret), nil
+}
+
+func mac(key []byte, id string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("session:"))
+	h.Write([]byte(id))
+	return h.Sum(nil)
+}
+
+// signID 返回 cookie 值: id.签名
+func signID(id string) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac(key, id)), nil
+}
+
+// verifyCookie 校验签名并返回会话id, 签名无效时返回 false
+func verifyCookie(value string) (string, bool) {
+	key, err := secret()
+	if err != nil {
+		return "", false
+	}
+	id, sig, ok := strings.Cut(value, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", false
+	}
+	return id, hmac.Equal(got, mac(key, id))
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/flaboy/aira-core/pkg/config"
+)
+
+func withSecret(t *testing.T, secret string) {
+	t.Helper()
+	old := config.Config
+	config.Config = &config.InfraConfig{AppSecret: secret}
+	t.Cleanup(func() { config.Config = old })
+}
+
+func TestSignAndVerify(t *testing.T) {
+	withSecret(t, "test-secret")
+
+	id := newID()
+	value, err := signID(id)
+	if err != nil {
+		t.Fatalf("signID: %v", err)
+	}
+	if !strings.HasPrefix(value, id+".") {
+		t.Fatalf("cookie value %q does not start with the id", value)
+	}
+	got, ok := verifyCookie(value)
+	if !ok || got != id {
+		t.Fatalf("verifyCookie(%q) = %q, %v; want %q, true", value, got, ok, id)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	withSecret(t, "test-secret")
+
+	id := newID()
+	value, err := signID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := signID(newID())
+	_, sig, _ := strings.Cut(value, ".")
+	_, otherSig, _ := strings.Cut(other, ".")
+	raw, _ := base64.RawURLEncoding.DecodeString(sig)
+	raw[0] ^= 1
+	flipped := base64.RawURLEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"id only", id},
+		{"empty id", "." + sig},
+		{"empty signature", id + "."},
+		{"modified id", "x" + id[1:] + "." + sig},
+		{"signature of another id", id + "." + otherSig},
+		{"flipped signature bit", id + "." + flipped},
+		{"truncated signature", id + "." + sig[:len(sig)-2]},
+		{"invalid base64", id + ".!!!"},
+		{"extra segment", value + ".extra"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := verifyCookie(tt.value); ok {
+				t.Fatalf("verifyCookie(%q) accepted, id %q", tt.value, got)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsOtherSecret(t *testing.T) {
+	withSecret(t, "secret-a")
+	value, err := signID(newID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withSecret(t, "secret-b")
+	if _, ok := verifyCookie(value); ok {
+		t.Fatal("cookie signed with another secret was accepted")
+	}
+}
+
+func TestNoSecret(t *testing.T) {
+	withSecret(t, "")
+	if _, err := signID(newID()); err != ErrNoSecret {
+		t.Fatalf("signID without secret: err = %v, want ErrNoSecret", err)
+	}
+	if _, ok := verifyCookie("id.sig"); ok {
+		t.Fatal("verifyCookie accepted a cookie without a secret")
+	}
+}
+
+func TestNewID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := newID()
+		if len(id) != 43 || strings.ContainsAny(id, ".=+/") {
+			t.Fatalf("unexpected id format %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %q", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+type contextKey struct{}
+
+// FromContext 返回 Middleware 放入请求 context 的会话, 没有时返回 nil
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(contextKey{}).(*Session)
+	return s
+}
+
+func NewContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// Middleware 加载会话并放入请求 context, 在响应头写出前保存修改:
+//
+//	store := session.Default()
+//	mux.Handle("/", store.Middleware(handler))
+//
+//	s := session.FromContext(r.Context())
+//	s.SetUser(user.ID)
+func (st *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := st.Load(r.Context(), r)
+		if err != nil {
+			slog.Error("session load failed", "error", err)
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		sw := &sessionWriter{ResponseWriter: w, store: st, session: s, ctx: r.Context()}
+		next.ServeHTTP(sw, r.WithContext(NewContext(r.Context(), s)))
+		sw.save()
+	})
+}
+
+// sessionWriter 在第一次写出响应头之前保存会话, 以便设置 cookie
+type sessionWriter struct {
+	http.ResponseWriter
+	store   *Store
+	session *Session
+	ctx     context.Context
+	saved   bool
+}
+
+func (w *sessionWriter) save() {
+	if w.saved {
+		return
+	}
+	w.saved = true
+	if err := w.store.Save(context.WithoutCancel(w.ctx), w.ResponseWriter, w.session); err != nil {
+		slog.Error("session save failed", "error", err)
+	}
+}
+
+func (w *sessionWriter) WriteHeader(status int) {
+	w.save()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.save()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *sessionWriter) Flush() {
+	w.save()
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap 供 http.ResponseController 访问底层的 ResponseWriter
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// record 是保存在 redis 中的会话数据
+type record struct {
+	UserID    string                     `json:"uid,omitempty"`
+	Values    map[string]json.RawMessage `json:"values,omitempty"`
+	CreatedAt time.Time                  `json:"created_at"`
+	LastSeen  time.Time                  `json:"last_seen"`
+	IP        string                     `json:"ip,omitempty"`
+	UserAgent string                     `json:"ua,omitempty"`
+}
+
+// Session 是一次请求中的会话. 修改只在响应写出前保存到 redis, 未修改的新会话不会被保存.
+type Session struct {
+	mu          sync.Mutex
+	id          string
+	rec         record
+	isNew       bool
+	dirty       bool
+	destroyed   bool
+	savedID     string // redis 中已保存的id, 轮换后保存时删除
+	savedUserID string
+}
+
+func newSession(ip, userAgent string) *Session {
+	now := time.Now()
+	return &Session{
+		id:    newID(),
+		isNew: true,
+		rec: record{
+			CreatedAt: now,
+			LastSeen:  now,
+			IP:        ip,
+			UserAgent: userAgent,
+		},
+	}
+}
+
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// IsNew 表示本次请求没有携带有效会话
+func (s *Session) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isNew
+}
+
+func (s *Session) UserID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rec.UserID
+}
+
+func (s *Session) CreatedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rec.CreatedAt
+}
+
+// Get 将 key 对应的值解码到 v, key 不存在时返回 false
+func (s *Session) Get(key string, v interface{}) (bool, error) {
+	s.mu.Lock()
+	raw, ok := s.rec.Values[key]
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Set 保存一个可 JSON 序列化的值
+func (s *Session) Set(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rec.Values == nil {
+		s.rec.Values = make(map[string]json.RawMessage)
+	}
+	s.rec.Values[key] = raw
+	s.dirty = true
+	return nil
+}
+
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rec.Values[key]; ok {
+		delete(s.rec.Values, key)
+		s.dirty = true
+	}
+}
+
+// SetUser 登录或切换用户, 权限发生变化时会同时轮换会话id, 防止会话固定攻击.
+// 传入空字符串表示登出但保留会话.
+func (s *Session) SetUser(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rec.UserID == userID {
+		return
+	}
+	s.rec.UserID = userID
+	s.rotateLocked()
+}
+
+// Rotate 更换会话id并保留数据, 用于提权等权限变化后
+func (s *Session) Rotate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotateLocked()
+}
+
+func (s *Session) rotateLocked() {
+	// 尚未保存的会话或本次请求中已轮换过的会话无需再次轮换
+	if s.savedID != "" && s.id == s.savedID {
+		s.id = newID()
+	}
+	s.dirty = true
+}
+
+// Destroy 删除会话并清除 cookie
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroyed = true
+}
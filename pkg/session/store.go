@@ -0,0 +1,303 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/redis"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type Options struct {
+	Name        string // redis key 前缀和默认 cookie 名, 默认 "session"
+	CookieName  string // 默认与 Name 相同
+	Path        string // 默认 "/"
+	Domain      string
+	Secure      bool
+	SameSite    http.SameSite // 默认 Lax
+	IdleTTL     time.Duration // 滑动过期时间, 每次访问后重新计算, 默认 30 分钟
+	AbsoluteTTL time.Duration // 自创建起的最长有效期, 默认 7 天
+}
+
+// Store 是基于 redis 的服务端会话存储. 会话数据按滑动过期保存,
+// 每个用户的会话id记录在一个有序集合中, 用于列出和批量吊销.
+type Store struct {
+	opts Options
+}
+
+func New(opts Options) *Store {
+	if opts.Name == "" {
+		opts.Name = "session"
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = opts.Name
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = 30 * time.Minute
+	}
+	if opts.AbsoluteTTL <= 0 {
+		opts.AbsoluteTTL = 7 * 24 * time.Hour
+	}
+	return &Store{opts: opts}
+}
+
+var defaultStore = New(Options{})
+
+// Default 返回默认会话存储
+func Default() *Store {
+	return defaultStore
+}
+
+// 访问时间的更新间隔, 避免每个请求都重写会话数据
+const touchInterval = time.Minute
+
+func (st *Store) key(id string) string {
+	return redis.Key(st.opts.Name, id)
+}
+
+func (st *Store) userKey(userID string) string {
+	return redis.Key(st.opts.Name, "user", userID)
+}
+
+// Load 读取请求携带的会话并延长有效期; 没有有效会话时返回一个新会话
+func (st *Store) Load(ctx context.Context, r *http.Request) (*Session, error) {
+	if c, err := r.Cookie(st.opts.CookieName); err == nil {
+		if id, ok := verifyCookie(c.Value); ok {
+			s, err := st.get(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if s != nil {
+				return s, nil
+			}
+		}
+	}
+	return newSession(clientIP(r), r.UserAgent()), nil
+}
+
+// get 读取会话并刷新过期时间, 不存在或已超过最长有效期时返回 nil
+func (st *Store) get(ctx context.Context, id string) (*Session, error) {
+	data, err := redis.RedisClient.GetEx(ctx, st.key(id), st.opts.IdleTTL).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, nil
+	}
+	if time.Since(rec.CreatedAt) >= st.opts.AbsoluteTTL {
+		st.delete(ctx, id, rec.UserID)
+		return nil, nil
+	}
+
+	s := &Session{
+		id:          id,
+		rec:         rec,
+		savedID:     id,
+		savedUserID: rec.UserID,
+	}
+	if time.Since(rec.LastSeen) >= touchInterval {
+		s.rec.LastSeen = time.Now()
+		s.dirty = true
+	}
+	return s, nil
+}
+
+// Save 将会话的修改写入 redis 并设置 cookie, 必须在写出响应头之前调用.
+// 使用 Middleware 时会自动调用.
+func (st *Store) Save(ctx context.Context, w http.ResponseWriter, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.destroyed {
+		hasCookie := !s.isNew || s.savedID != ""
+		if s.savedID != "" {
+			if err := st.delete(ctx, s.savedID, s.savedUserID); err != nil {
+				return err
+			}
+			s.savedID, s.savedUserID = "", ""
+		}
+		if hasCookie {
+			http.SetCookie(w, st.cookie("", -1))
+		}
+		return nil
+	}
+	if !s.dirty {
+		return nil
+	}
+
+	value, err := signID(s.id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&s.rec)
+	if err != nil {
+		return err
+	}
+	ttl := min(st.opts.IdleTTL, time.Until(s.rec.CreatedAt.Add(st.opts.AbsoluteTTL)))
+	if ttl <= 0 {
+		return nil
+	}
+
+	_, err = redis.RedisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Set(ctx, st.key(s.id), data, ttl)
+		if s.savedID != "" && s.savedID != s.id {
+			pipe.Del(ctx, st.key(s.savedID))
+		}
+		if s.savedUserID != "" && (s.savedUserID != s.rec.UserID || s.savedID != s.id) {
+			pipe.ZRem(ctx, st.userKey(s.savedUserID), s.savedID)
+		}
+		if s.rec.UserID != "" {
+			userKey := st.userKey(s.rec.UserID)
+			pipe.ZAdd(ctx, userKey, goredis.Z{Score: float64(s.rec.CreatedAt.UnixMilli()), Member: s.id})
+			pipe.Expire(ctx, userKey, st.opts.AbsoluteTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 新会话或id轮换后才需要下发 cookie
+	if s.savedID != s.id {
+		http.SetCookie(w, st.cookie(value, 0))
+	}
+	s.savedID, s.savedUserID = s.id, s.rec.UserID
+	s.dirty = false
+	return nil
+}
+
+func (st *Store) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     st.opts.CookieName,
+		Value:    value,
+		Path:     st.opts.Path,
+		Domain:   st.opts.Domain,
+		MaxAge:   maxAge,
+		Secure:   st.opts.Secure,
+		HttpOnly: true,
+		SameSite: st.opts.SameSite,
+	}
+}
+
+func (st *Store) delete(ctx context.Context, id, userID string) error {
+	_, err := redis.RedisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Del(ctx, st.key(id))
+		if userID != "" {
+			pipe.ZRem(ctx, st.userKey(userID), id)
+		}
+		return nil
+	})
+	return err
+}
+
+// Info 是会话的摘要信息. ID 可以用于 Revoke, 不应原样展示给客户端.
+type Info struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// List 返回用户当前有效的会话, 按创建时间排序, 同时清理已过期的索引
+func (st *Store) List(ctx context.Context, userID string) ([]Info, error) {
+	userKey := st.userKey(userID)
+	ids, err := redis.RedisClient.ZRange(ctx, userKey, 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	cmds := make([]*goredis.StringCmd, len(ids))
+	_, err = redis.RedisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.Get(ctx, st.key(id))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	var list []Info
+	var expired []interface{}
+	for i, cmd := range cmds {
+		var rec record
+		data, err := cmd.Bytes()
+		if err != nil || json.Unmarshal(data, &rec) != nil || rec.UserID != userID ||
+			time.Since(rec.CreatedAt) >= st.opts.AbsoluteTTL {
+			expired = append(expired, ids[i])
+			continue
+		}
+		list = append(list, Info{
+			ID:        ids[i],
+			UserID:    rec.UserID,
+			CreatedAt: rec.CreatedAt,
+			LastSeen:  rec.LastSeen,
+			IP:        rec.IP,
+			UserAgent: rec.UserAgent,
+		})
+	}
+	if len(expired) > 0 {
+		redis.RedisClient.ZRem(ctx, userKey, expired...)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// Revoke 吊销指定会话
+func (st *Store) Revoke(ctx context.Context, id string) error {
+	var rec record
+	data, err := redis.RedisClient.Get(ctx, st.key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	json.Unmarshal(data, &rec)
+	return st.delete(ctx, id, rec.UserID)
+}
+
+// RevokeAll 吊销用户的所有会话, except 中的会话id (如当前会话) 会被保留
+func (st *Store) RevokeAll(ctx context.Context, userID string, except ...string) error {
+	userKey := st.userKey(userID)
+	ids, err := redis.RedisClient.ZRange(ctx, userKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(except))
+	for _, id := range except {
+		keep[id] = true
+	}
+
+	_, err = redis.RedisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, id := range ids {
+			if keep[id] {
+				continue
+			}
+			// 逐个删除, cluster 模式下 key 可能不在同一个 slot
+			pipe.Del(ctx, st.key(id))
+			pipe.ZRem(ctx, userKey, id)
+		}
+		return nil
+	})
+	return err
+}
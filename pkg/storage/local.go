@@ -131,7 +131,7 @@ func (s *LocalStorage) Move(src, dst string) error {
 
 func (s *LocalStorage) GetUploadContext(ctx context.Context, path string) (*UploadContext, error) {
 	id := uuid.New().String()
-	smtp := redis.RedisClient.Set(ctx, id, path, 0)
+	smtp := redis.RedisClient.Set(ctx, uploadTokenKey(id), path, 0)
 	if smtp.Err() != nil {
 		return nil, smtp.Err()
 	}
@@ -143,6 +143,10 @@ func (s *LocalStorage) GetUploadContext(ctx context.Context, path string) (*Uplo
 	}, nil
 }
 
+func uploadTokenKey(token string) string {
+	return redis.Key("upload", token)
+}
+
 // SetObjectACL is a no-op for local storage (ACL not applicable)
 func (s *LocalStorage) SetObjectACL(path string, acl interface{}) error {
 	// Local storage doesn't support ACL, so this is a no-op
@@ -176,7 +180,7 @@ func (s *LocalStorage) UploadHttpRequest(req *http.Request) (map[string]interfac
 		return nil, errors.New("path is required")
 	}
 
-	id := redis.RedisClient.Get(req.Context(), token)
+	id := redis.RedisClient.Get(req.Context(), uploadTokenKey(token))
 	if id.Err() != nil {
 		log.Printf("2. Redis error: %v", id.Err())
 		return nil, id.Err()
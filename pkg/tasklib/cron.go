@@ -0,0 +1,198 @@
+package tasklib
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/cluster"
+	"github.com/flaboy/aira-core/pkg/config"
+	"github.com/flaboy/aira-core/pkg/redis"
+
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// 定时任务由该选举的主节点触发
+	schedulerElection = "tasklib_scheduler"
+
+	// 切换主节点后补发错过的触发, 超过该时长的不再补发
+	cronCatchUp = 10 * time.Minute
+	// 每次触发使用固定的任务id, 保留期内重复入队会被 asynq 拒绝. 补发最多回溯 cronCatchUp,
+	// 保留期只需覆盖补发窗口并留出余量
+	cronRetention = cronCatchUp + 5*time.Minute
+)
+
+type cronEntry struct {
+	id       string
+	spec     string
+	schedule cron.Schedule
+	taskName string
+	payload  []byte
+	opts     []asynq.Option
+}
+
+var (
+	cronEntries []*cronEntry
+	cronCtx     context.Context // 本节点为调度主节点时非 nil
+	cronMu      sync.Mutex
+	cronOnce    sync.Once
+)
+
+// initScheduler 参与调度选主, 当选后开始触发定时任务, 降级时停止
+func initScheduler() {
+	cronOnce.Do(func() {
+		election := cluster.GetElection(schedulerElection)
+		if election == nil {
+			election = cluster.NewElection(schedulerElection, nil)
+		}
+		election.OnElected(runScheduler)
+	})
+}
+
+func runScheduler(ctx context.Context) {
+	cronMu.Lock()
+	cronCtx = ctx
+	entries := append([]*cronEntry(nil), cronEntries...)
+	cronMu.Unlock()
+
+	slog.Info("Task scheduler started", "entries", len(entries))
+	for _, e := range entries {
+		go e.run(ctx)
+	}
+
+	<-ctx.Done()
+	cronMu.Lock()
+	if cronCtx == ctx {
+		cronCtx = nil
+	}
+	cronMu.Unlock()
+	slog.Info("Task scheduler stopped")
+}
+
+// CronTask 注册定时任务, spec 为标准 cron 表达式 (支持 @every 等描述符), 按 DEFAULT_TIMEZONE 计算.
+// 定时任务只由调度主节点触发; 切换主节点时会补发最近错过的触发, 每次触发只入队一次.
+// 所有节点应注册相同的定时任务, 相同任务名、表达式和参数的重复注册返回同一个 entryID.
+func CronTask(taskName, spec string, v interface{}, opts ...asynq.Option) (entryID string, err error) {
+	payload, err := payload(v)
+	if err != nil {
+		return "", err
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return "", err
+	}
+
+	e := &cronEntry{
+		id:       cronEntryID(taskName, spec, payload),
+		spec:     spec,
+		schedule: schedule,
+		taskName: taskName,
+		payload:  payload,
+		opts:     opts,
+	}
+
+	cronMu.Lock()
+	defer cronMu.Unlock()
+	for _, existing := range cronEntries {
+		if existing.id == e.id {
+			return e.id, nil
+		}
+	}
+	cronEntries = append(cronEntries, e)
+	if cronCtx != nil {
+		go e.run(cronCtx)
+	}
+	return e.id, nil
+}
+
+// cronEntryID 由任务名、表达式和参数决定, 各节点注册的相同定时任务得到相同的id
+func cronEntryID(taskName, spec string, payload []byte) string {
+	sum := sha1.Sum(append([]byte(spec+"\x00"), payload...))
+	return taskName + ":" + hex.EncodeToString(sum[:6])
+}
+
+func location() *time.Location {
+	loc, err := time.LoadLocation(config.Config.DefaultTimezone)
+	if err != nil {
+		slog.Warn("Task scheduler invalid timezone, using local", "timezone", config.Config.DefaultTimezone, "error", err)
+		return time.Local
+	}
+	return loc
+}
+
+func (e *cronEntry) lastTickKey() string {
+	return redis.Key("cron", e.id, "last")
+}
+
+// run 按计划触发任务直到 ctx 结束, 从 redis 中记录的上次触发时间继续
+func (e *cronEntry) run(ctx context.Context) {
+	loc := location()
+	var recorded time.Time
+	if v, err := redis.RedisClient.Get(ctx, e.lastTickKey()).Int64(); err == nil {
+		recorded = time.Unix(v, 0)
+	} else if !errors.Is(err, redis.Nil) {
+		slog.Warn("Task scheduler read last tick failed", "entry", e.id, "error", err)
+	}
+	last := resumeFrom(recorded, time.Now())
+	if !recorded.IsZero() && last.After(recorded) {
+		slog.Warn("Task scheduler skipped missed ticks", "entry", e.id, "last", recorded)
+	}
+
+	for {
+		next := e.schedule.Next(last.In(loc))
+		if next.IsZero() {
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := e.enqueue(ctx, next); err != nil {
+			slog.Error("Task scheduler enqueue failed", "entry", e.id, "tick", next, "error", err)
+		}
+		last = next
+	}
+}
+
+// resumeFrom 返回继续计算下一次触发的起点: 没有记录时从当前时间开始,
+// 记录早于 cronCatchUp 时只补发最近 cronCatchUp 内的触发
+func resumeFrom(recorded, now time.Time) time.Time {
+	if recorded.IsZero() {
+		return now
+	}
+	if now.Sub(recorded) > cronCatchUp {
+		return now.Add(-cronCatchUp)
+	}
+	return recorded
+}
+
+// taskID 是一次触发的任务id, 同一触发由不同节点入队时相同
+func (e *cronEntry) taskID(tick time.Time) string {
+	return fmt.Sprintf("cron:%s:%d", e.id, tick.Unix())
+}
+
+// enqueue 入队一次触发. 任务id由 entryID 和触发时间决定, 新旧主节点重叠时不会重复入队.
+func (e *cronEntry) enqueue(ctx context.Context, tick time.Time) error {
+	opts := append([]asynq.Option{
+		asynq.Queue(QueueName(config.Config.AsynqName.Default)),
+		asynq.Retention(cronRetention),
+	}, e.opts...)
+	opts = append(opts, asynq.TaskID(e.taskID(tick)))
+
+	_, err := client.EnqueueContext(ctx, asynq.NewTask(e.taskName, e.payload), opts...)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err
+	}
+	return redis.RedisClient.Set(ctx, e.lastTickKey(), strconv.FormatInt(tick.Unix(), 10), 30*24*time.Hour).Err()
+}
@@ -0,0 +1,133 @@
+package tasklib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestResumeFrom(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 12, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		recorded time.Time
+		want     time.Time
+	}{
+		{"no record starts now", time.Time{}, now},
+		{"recent record", now.Add(-8 * time.Minute), now.Add(-8 * time.Minute)},
+		{"record at window edge", now.Add(-cronCatchUp), now.Add(-cronCatchUp)},
+		{"old record is capped", now.Add(-72 * time.Minute), now.Add(-cronCatchUp)},
+	}
+	for _, tt := range tests {
+		if got := resumeFrom(tt.recorded, now); !got.Equal(tt.want) {
+			t.Errorf("%s: resumeFrom = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCatchUpTicks(t *testing.T) {
+	schedule, err := cron.ParseStandard("*/5 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 12, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return time.Date(2026, 1, 1, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		recorded time.Time
+		want     []time.Time
+	}{
+		{"first run only schedules future ticks", time.Time{}, nil},
+		{"failover replays missed ticks", at(12, 4), []time.Time{at(12, 5), at(12, 10)}},
+		{"long outage replays only the window", at(9, 0), []time.Time{at(12, 5), at(12, 10)}},
+		{"window drops ticks before it", at(11, 50), []time.Time{at(12, 5), at(12, 10)}},
+		{"up to date", at(12, 10), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var due []time.Time
+			for tick := schedule.Next(resumeFrom(tt.recorded, now)); !tick.After(now); tick = schedule.Next(tick) {
+				due = append(due, tick)
+			}
+			if len(due) != len(tt.want) {
+				t.Fatalf("due ticks = %v, want %v", due, tt.want)
+			}
+			for i := range due {
+				if !due[i].Equal(tt.want[i]) {
+					t.Fatalf("due ticks = %v, want %v", due, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCronEntryID(t *testing.T) {
+	base := cronEntryID("report:daily", "0 3 * * *", []byte(`{"a":1}`))
+	tests := []struct {
+		name     string
+		taskName string
+		spec     string
+		payload  string
+		same     bool
+	}{
+		{"identical registration", "report:daily", "0 3 * * *", `{"a":1}`, true},
+		{"different payload", "report:daily", "0 3 * * *", `{"a":2}`, false},
+		{"different spec", "report:daily", "0 4 * * *", `{"a":1}`, false},
+		{"different task", "report:weekly", "0 3 * * *", `{"a":1}`, false},
+		{"spec and payload boundary", "report:daily", "0 3 * * *{", `"a":1}`, false},
+	}
+	for _, tt := range tests {
+		got := cronEntryID(tt.taskName, tt.spec, []byte(tt.payload))
+		if (got == base) != tt.same {
+			t.Errorf("%s: cronEntryID = %q, base %q, same = %v", tt.name, got, base, tt.same)
+		}
+	}
+}
+
+func TestCronTaskID(t *testing.T) {
+	e := &cronEntry{id: "report:daily:0123456789ab"}
+	other := &cronEntry{id: "report:daily:0123456789ab"}
+	tick := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	if e.taskID(tick) != other.taskID(tick.In(time.FixedZone("CST", 8*3600))) {
+		t.Fatal("same tick in another timezone has a different task id")
+	}
+	if e.taskID(tick) == e.taskID(tick.Add(time.Minute)) {
+		t.Fatal("different ticks share a task id")
+	}
+	if want := "cron:report:daily:0123456789ab:1767236400"; e.taskID(tick) != want {
+		t.Fatalf("taskID = %q, want %q", e.taskID(tick), want)
+	}
+}
+
+func TestCronTaskDuplicate(t *testing.T) {
+	id1, err := CronTask("test:cron-dup", "*/10 * * * *", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := CronTask("test:cron-dup", "*/10 * * * *", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id1 != id2 {
+		t.Fatalf("duplicate registration returned %q and %q", id1, id2)
+	}
+
+	cronMu.Lock()
+	n := 0
+	for _, e := range cronEntries {
+		if e.id == id1 {
+			n++
+		}
+	}
+	cronMu.Unlock()
+	if n != 1 {
+		t.Fatalf("entry registered %d times", n)
+	}
+
+	if _, err := CronTask("test:cron-bad", "not a spec", nil); err == nil {
+		t.Fatal("invalid spec accepted")
+	}
+}
@@ -0,0 +1,159 @@
+package tasklib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/config"
+
+	"github.com/hibiken/asynq"
+)
+
+// Validator 由任务参数实现, 入队前和处理前都会校验
+type Validator interface {
+	Validate() error
+}
+
+type Priority int
+
+const (
+	PriorityDefault Priority = iota // ASYNQ_NAME_DEFAULT 队列
+	PriorityHigh                    // ASYNQ_NAME_HIGH 队列
+	PriorityLow                     // ASYNQ_NAME_LOW 队列
+)
+
+// TaskOptions 是 Define 的选项, 作为每次入队的默认值, 可以被 Enqueue 的 opts 覆盖
+type TaskOptions struct {
+	Priority  Priority
+	Queue     string        // 指定队列名, 优先于 Priority; 自定义队列须在 tasklib.Init 之前定义, 由 Init 加入 asynq server
+	MaxRetry  int           // 最大重试次数, 0 使用 asynq 默认值, 负数表示不重试
+	Timeout   time.Duration // 单次执行超时, 0 使用 asynq 默认值
+	Retention time.Duration // 完成后保留时长
+	Unique    time.Duration // 在此期间相同类型和参数的任务只入队一次
+}
+
+// TaskDef 是类型化的任务定义
+type TaskDef[T any] struct {
+	name string
+	opts TaskOptions
+}
+
+// Define 定义参数类型为 T 的任务, 通常作为包级变量:
+//
+//	var SendWelcome = tasklib.Define[WelcomeArgs]("user:welcome", &tasklib.TaskOptions{
+//		Priority: tasklib.PriorityHigh,
+//		MaxRetry: 5,
+//		Timeout:  time.Minute,
+//	})
+//
+//	SendWelcome.Handle(func(ctx context.Context, args WelcomeArgs) error { ... })
+//	SendWelcome.Enqueue(ctx, WelcomeArgs{UserID: id})
+func Define[T any](name string, opts *TaskOptions) *TaskDef[T] {
+	d := &TaskDef[T]{name: name}
+	if opts != nil {
+		d.opts = *opts
+	}
+	if d.opts.Queue != "" {
+		addQueue(d.opts.Queue)
+	}
+	return d
+}
+
+// customQueues 是 Define 指定的自定义队列, Init 时与默认的三个队列一起交给 asynq server
+var customQueues = make(map[string]bool)
+
+func addQueue(name string) {
+	lk.Lock()
+	defer lk.Unlock()
+	if customQueues[name] {
+		return
+	}
+	if server != nil {
+		panic("tasklib: queue " + name + " must be defined before tasklib.Init")
+	}
+	customQueues[name] = true
+}
+
+func (d *TaskDef[T]) Name() string {
+	return d.name
+}
+
+// queue 在入队时解析, Define 通常在加载配置之前执行
+func (d *TaskDef[T]) queue() string {
+	if d.opts.Queue != "" {
+		return QueueName(d.opts.Queue)
+	}
+	switch d.opts.Priority {
+	case PriorityHigh:
+		return QueueName(config.Config.AsynqName.High)
+	case PriorityLow:
+		return QueueName(config.Config.AsynqName.Low)
+	}
+	return QueueName(config.Config.AsynqName.Default)
+}
+
+func (d *TaskDef[T]) options() []asynq.Option {
+	opts := []asynq.Option{asynq.Queue(d.queue())}
+	switch {
+	case d.opts.MaxRetry > 0:
+		opts = append(opts, asynq.MaxRetry(d.opts.MaxRetry))
+	case d.opts.MaxRetry < 0:
+		opts = append(opts, asynq.MaxRetry(0))
+	}
+	if d.opts.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(d.opts.Timeout))
+	}
+	if d.opts.Retention > 0 {
+		opts = append(opts, asynq.Retention(d.opts.Retention))
+	}
+	if d.opts.Unique > 0 {
+		opts = append(opts, asynq.Unique(d.opts.Unique))
+	}
+	return opts
+}
+
+// Enqueue 校验参数并入队, opts 覆盖 Define 中的默认值
+func (d *TaskDef[T]) Enqueue(ctx context.Context, payload T, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	if err := validate(&payload); err != nil {
+		return nil, fmt.Errorf("tasklib: invalid %s payload: %w", d.name, err)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	task := asynq.NewTask(d.name, data)
+	return client.EnqueueContext(ctx, task, append(d.options(), opts...)...)
+}
+
+// Handle 注册处理函数. 参数无法解码或校验失败时任务不会重试.
+func (d *TaskDef[T]) Handle(fn func(ctx context.Context, payload T) error) {
+	Consumer(d.name, d.handler(fn))
+}
+
+func (d *TaskDef[T]) handler(fn func(ctx context.Context, payload T) error) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		var payload T
+		if len(task.Payload()) > 0 {
+			if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+				return fmt.Errorf("decode %s payload: %v: %w", d.name, err, asynq.SkipRetry)
+			}
+		}
+		if err := validate(&payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %v: %w", d.name, err, asynq.SkipRetry)
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// validate 在 T 或 *T 实现 Validator 时校验参数
+func validate[T any](payload *T) error {
+	if v, ok := any(payload).(Validator); ok {
+		return v.Validate()
+	}
+	if v, ok := any(*payload).(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package tasklib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flaboy/aira-core/pkg/config"
+
+	"github.com/hibiken/asynq"
+)
+
+type valueArgs struct {
+	ID int `json:"id"`
+}
+
+func (a valueArgs) Validate() error {
+	if a.ID <= 0 {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+type pointerArgs struct {
+	Name string `json:"name"`
+}
+
+func (a *pointerArgs) Validate() error {
+	if a.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type plainArgs struct {
+	N int `json:"n"`
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{"value receiver ok", validate(&valueArgs{ID: 1}), false},
+		{"value receiver fails", validate(&valueArgs{}), true},
+		{"pointer receiver ok", validate(&pointerArgs{Name: "a"}), false},
+		{"pointer receiver fails", validate(&pointerArgs{}), true},
+		{"no validator", validate(&plainArgs{}), false},
+	}
+	for _, tt := range tests {
+		if (tt.err != nil) != tt.wantErr {
+			t.Errorf("%s: validate error = %v, wantErr %v", tt.name, tt.err, tt.wantErr)
+		}
+	}
+}
+
+func TestHandlerSkipRetry(t *testing.T) {
+	handlerErr := errors.New("temporary failure")
+	tests := []struct {
+		name      string
+		payload   string
+		fnErr     error
+		wantErr   error
+		wantSkip  bool
+		wantCalls int
+	}{
+		{"valid payload", `{"id":1}`, nil, nil, false, 1},
+		{"handler error is retried", `{"id":1}`, handlerErr, handlerErr, false, 1},
+		{"undecodable payload", `{"id":"x"}`, nil, nil, true, 0},
+		{"invalid payload", `{"id":0}`, nil, nil, true, 0},
+		{"empty payload is validated", ``, nil, nil, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			d := Define[valueArgs]("test:args", nil)
+			h := d.handler(func(ctx context.Context, args valueArgs) error {
+				calls++
+				return tt.fnErr
+			})
+			err := h.ProcessTask(context.Background(), asynq.NewTask(d.Name(), []byte(tt.payload)))
+			if calls != tt.wantCalls {
+				t.Fatalf("handler called %d times, want %d", calls, tt.wantCalls)
+			}
+			if got := errors.Is(err, asynq.SkipRetry); got != tt.wantSkip {
+				t.Fatalf("error %v: SkipRetry = %v, want %v", err, got, tt.wantSkip)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !tt.wantSkip && tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestEnqueueRejectsInvalidPayload(t *testing.T) {
+	d := Define[pointerArgs]("test:pointer", nil)
+	if _, err := d.Enqueue(context.Background(), pointerArgs{}); err == nil {
+		t.Fatal("Enqueue accepted an invalid payload")
+	}
+}
+
+func TestTaskOptions(t *testing.T) {
+	old := config.Config
+	config.Config = &config.InfraConfig{}
+	config.Config.AsynqName.Default = "app"
+	config.Config.AsynqName.High = "app-high"
+	config.Config.AsynqName.Low = "app-low"
+	t.Cleanup(func() { config.Config = old })
+
+	tests := []struct {
+		name  string
+		opts  *TaskOptions
+		queue string
+		retry int // -1 表示不设置
+	}{
+		{"defaults", nil, "app", -1},
+		{"high priority", &TaskOptions{Priority: PriorityHigh}, "app-high", -1},
+		{"low priority", &TaskOptions{Priority: PriorityLow}, "app-low", -1},
+		{"queue overrides priority", &TaskOptions{Priority: PriorityHigh, Queue: "app-high"}, "app-high", -1},
+		{"max retry", &TaskOptions{MaxRetry: 5}, "app", 5},
+		{"no retry", &TaskOptions{MaxRetry: -1}, "app", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Define[plainArgs]("test:options", tt.opts)
+			queue, retry := "", -1
+			for _, o := range d.options() {
+				switch o.Type() {
+				case asynq.QueueOpt:
+					queue = o.Value().(string)
+				case asynq.MaxRetryOpt:
+					retry = o.Value().(int)
+				}
+			}
+			if queue != tt.queue || retry != tt.retry {
+				t.Fatalf("queue, retry = %q, %d; want %q, %d", queue, retry, tt.queue, tt.retry)
+			}
+		})
+	}
+
+	d := Define[plainArgs]("test:timeouts", &TaskOptions{Timeout: time.Minute, Retention: time.Hour, Unique: time.Second})
+	types := make(map[asynq.OptionType]bool)
+	for _, o := range d.options() {
+		types[o.Type()] = true
+	}
+	for _, typ := range []asynq.OptionType{asynq.TimeoutOpt, asynq.RetentionOpt, asynq.UniqueOpt} {
+		if !types[typ] {
+			t.Errorf("option %v not set", typ)
+		}
+	}
+}
@@ -31,17 +31,25 @@ func init() {
 }
 
 func Init() error {
+	queues := map[string]int{
+		QueueName(config.Config.AsynqName.Default): 2,
+		QueueName(config.Config.AsynqName.High):    2,
+		QueueName(config.Config.AsynqName.Low):     1,
+	}
+	lk.Lock()
+	for name := range customQueues {
+		if _, ok := queues[QueueName(name)]; !ok {
+			queues[QueueName(name)] = 1
+		}
+	}
 	server = asynq.NewServer(
 		&redisConnector{},
 		asynq.Config{
 			Concurrency: 16,
-			Queues: map[string]int{
-				config.Config.AsynqName.Default: 2,
-				config.Config.AsynqName.High:    2,
-				config.Config.AsynqName.Low:     1,
-			},
+			Queues:      queues,
 		},
 	)
+	lk.Unlock()
 
 	client = asynq.NewClient(
 		&redisConnector{},
@@ -51,15 +59,23 @@ func Init() error {
 		&redisConnector{},
 	)
 
-	scheduler = asynq.NewScheduler(
-		&redisConnector{},
-		&asynq.SchedulerOpts{},
-	)
+	initScheduler()
 
 	return nil
 }
 
+// Scheduler 返回 asynq 原生的 scheduler, 由调用方负责启动, 所有节点都会触发.
+//
+// Deprecated: 使用 CronTask, 定时任务只由调度主节点触发且每次触发只入队一次.
 func Scheduler() *asynq.Scheduler {
+	lk.Lock()
+	defer lk.Unlock()
+	if scheduler == nil {
+		scheduler = asynq.NewScheduler(
+			&redisConnector{},
+			&asynq.SchedulerOpts{},
+		)
+	}
 	return scheduler
 }
 
@@ -67,6 +83,13 @@ func StartServer() error {
 	return server.Start(mux)
 }
 
+// QueueName 给队列名加上 REDIS_KEY_PREFIX. asynq 的 key 形如 asynq:{queue}:..., 队列名带前缀后
+// 各应用的任务数据互相隔离; asynq:queues 等全局索引仍共用, 但只记录队列和进程信息.
+func QueueName(name string) string {
+	return redis.Key(name)
+}
+
+// redisConnector 让 asynq 复用 redis.RedisClient, 兼容单机、sentinel 和 cluster 模式
 type redisConnector struct {
 }
 
@@ -101,17 +124,17 @@ func Task(taskName string, v interface{}, opts ...asynq.Option) error {
 }
 
 func EnqueueLowTask(taskName string, v interface{}, opts ...asynq.Option) error {
-	opts = append(opts, asynq.Queue(config.Config.AsynqName.Low))
+	opts = append(opts, asynq.Queue(QueueName(config.Config.AsynqName.Low)))
 	return Task(taskName, v, opts...)
 }
 
 func EnqueueHighTask(taskName string, v interface{}, opts ...asynq.Option) error {
-	opts = append(opts, asynq.Queue(config.Config.AsynqName.High))
+	opts = append(opts, asynq.Queue(QueueName(config.Config.AsynqName.High)))
 	return Task(taskName, v, opts...)
 }
 
 func EnqueueTask(taskName string, v interface{}, opts ...asynq.Option) error {
-	opts = append(opts, asynq.Queue(config.Config.AsynqName.Default))
+	opts = append(opts, asynq.Queue(QueueName(config.Config.AsynqName.Default)))
 	return Task(taskName, v, opts...)
 }
 
@@ -127,16 +150,6 @@ func payload(v interface{}) ([]byte, error) {
 	}
 }
 
-func CronTask(taskName, spec string, v interface{}, opts ...asynq.Option) (entryID string, err error) {
-	payload, err := payload(v)
-	if err != nil {
-		return "", err
-	}
-	task := asynq.NewTask(taskName, payload)
-	opts = append(opts, asynq.Queue(config.Config.AsynqName.Default))
-	return scheduler.Register(spec, task, opts...)
-}
-
 // ScheduleTask 用于延迟任务
 func ScheduleTask(taskName string, t time.Time, v interface{}, opts ...asynq.Option) (*asynq.TaskInfo, error) {
 	payload, err := payload(v)
@@ -144,7 +157,7 @@ func ScheduleTask(taskName string, t time.Time, v interface{}, opts ...asynq.Opt
 		return nil, err
 	}
 	task := asynq.NewTask(taskName, payload)
-	opts = append(opts, asynq.Queue(config.Config.AsynqName.Default))
+	opts = append(opts, asynq.Queue(QueueName(config.Config.AsynqName.Default)))
 	opts = append(opts, asynq.ProcessAt(t))
 	return client.Enqueue(task, opts...)
 }
@@ -176,5 +189,5 @@ func (w *taskHandlerProxy) ProcessTask(ctx context.Context, message *asynq.Task)
 }
 
 func Cancel(taskID string) error {
-	return inspector.DeleteTask(config.Config.AsynqName.Default, taskID)
+	return inspector.DeleteTask(QueueName(config.Config.AsynqName.Default), taskID)
 }